@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/koestler/dnsdock/containerFilter"
+	"github.com/koestler/dnsdock/dnsStorage"
+	"github.com/koestler/dnsdock/naming"
+)
+
+// Config is read from the json file given by the CONFIG_FILE environment
+// variable. It is read again on SIGHUP or POST /api/v0/reload.
+type Config struct {
+	// StaticRecords are names for things outside of docker, e.g. the host machine
+	StaticRecords []dnsStorage.Record
+	// LogLevel overrides the LOG_LEVEL environment variable if set
+	LogLevel string
+	// Ttl is the time to live of all answers in seconds
+	Ttl uint32
+	// Naming selects how containers are named, it overrides the NAMING_STRATEGY
+	// and NAMING_TEMPLATE environment variables if set. Containers keep their
+	// names until they are started again.
+	Naming naming.Config
+	// ConflictPolicy is applied to names claimed by different containers, it
+	// overrides the CONFLICT_POLICY environment variable if set
+	ConflictPolicy string
+	// NetworkScope is one of off, prefer or restrict, it overrides the
+	// NETWORK_SCOPE environment variable if set
+	NetworkScope string
+	// Filter selects the containers, swarm services and networks registered,
+	// by default all of them. Running containers are filtered when they are
+	// started again.
+	Filter containerFilter.Config
+}
+
+func readConfig(path string) (config Config, err error) {
+	if path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &config)
+	return
+}
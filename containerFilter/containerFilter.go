@@ -0,0 +1,164 @@
+package containerFilter
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types/swarm"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// Config selects the containers and networks registered. Networks and images
+// are matched by globs, e.g. build_*, labels by selectors of the form key or
+// key=value and container names by regular expressions. Empty include lists
+// include everything, excludes take precedence over includes.
+type Config struct {
+	IncludeNetworks []string
+	ExcludeNetworks []string
+	IncludeImages   []string
+	ExcludeImages   []string
+	IncludeLabels   []string
+	ExcludeLabels   []string
+	IncludeNames    []string
+	ExcludeNames    []string
+}
+
+type Filter struct {
+	config       Config
+	includeNames []*regexp.Regexp
+	excludeNames []*regexp.Regexp
+}
+
+// New validates the patterns of config
+func New(config Config) (*Filter, error) {
+	for _, patterns := range [][]string{config.IncludeNetworks, config.ExcludeNetworks, config.IncludeImages, config.ExcludeImages} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+		}
+	}
+
+	f := &Filter{config: config}
+	var err error
+	if f.includeNames, err = compile(config.IncludeNames); err != nil {
+		return nil, err
+	}
+	if f.excludeNames, err = compile(config.ExcludeNames); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func compile(expressions []string) (regexps []*regexp.Regexp, err error) {
+	for _, expression := range expressions {
+		r, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", expression, err)
+		}
+		regexps = append(regexps, r)
+	}
+	return
+}
+
+// Container reports whether the container is registered by its image, labels and name
+func (f *Filter) Container(container *docker.Container) bool {
+	var image string
+	var labels map[string]string
+	if container.Config != nil {
+		image = container.Config.Image
+		labels = container.Config.Labels
+	}
+	return f.matches(image, labels, strings.Trim(container.Name, "/"))
+}
+
+// Service reports whether the swarm service is registered by the image of its
+// tasks, its labels and its name. Labels of the containers of the tasks are
+// matched as well, the ones of the service take precedence.
+func (f *Filter) Service(service swarm.Service) bool {
+	var image string
+	labels := make(map[string]string)
+	if spec := service.Spec.TaskTemplate.ContainerSpec; spec != nil {
+		// images are pinned to a digest, e.g. nginx:1.25@sha256:...
+		image, _, _ = strings.Cut(spec.Image, "@")
+		maps.Copy(labels, spec.Labels)
+	}
+	maps.Copy(labels, service.Spec.Labels)
+	return f.matches(image, labels, service.Spec.Name)
+}
+
+func (f *Filter) matches(image string, labels map[string]string, name string) bool {
+	return included(f.config.IncludeImages, f.config.ExcludeImages, func(pattern string) bool {
+		matched, _ := path.Match(pattern, image)
+		return matched
+	}) && included(f.config.IncludeLabels, f.config.ExcludeLabels, func(selector string) bool {
+		return matchLabel(selector, labels)
+	}) && included(f.includeNames, f.excludeNames, func(r *regexp.Regexp) bool {
+		return r.MatchString(name)
+	})
+}
+
+// Network reports whether the addresses on the network are registered
+func (f *Filter) Network(network string) bool {
+	return included(f.config.IncludeNetworks, f.config.ExcludeNetworks, func(pattern string) bool {
+		matched, _ := path.Match(pattern, network)
+		return matched
+	})
+}
+
+// matchLabel matches selectors of the form key or key=value
+func matchLabel(selector string, labels map[string]string) bool {
+	if key, value, ok := strings.Cut(selector, "="); ok {
+		actual, exists := labels[key]
+		return exists && actual == value
+	}
+	_, exists := labels[selector]
+	return exists
+}
+
+// included reports whether any include matches, or there are none, and no exclude matches
+func included[T any](includes, excludes []T, match func(T) bool) bool {
+	for _, exclude := range excludes {
+		if match(exclude) {
+			return false
+		}
+	}
+	if len(includes) == 0 {
+		return true
+	}
+	for _, include := range includes {
+		if match(include) {
+			return true
+		}
+	}
+	return false
+}
+
+// Selector holds the filter in use, it can be replaced when the
+// configuration is reloaded
+type Selector struct {
+	mutex  sync.RWMutex
+	filter *Filter
+}
+
+func NewSelector(filter *Filter) *Selector {
+	return &Selector{filter: filter}
+}
+
+func (s *Selector) Set(filter *Filter) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.filter = filter
+}
+
+func (s *Selector) Get() *Filter {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.filter
+}
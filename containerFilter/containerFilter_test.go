@@ -0,0 +1,119 @@
+package containerFilter
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+func TestContainer(t *testing.T) {
+	web := &docker.Container{Name: "/shop-web-1", Config: &docker.Config{
+		Image:  "nginx:1.25",
+		Labels: map[string]string{"com.docker.compose.project": "shop", "tier": "front"},
+	}}
+	builder := &docker.Container{Name: "/buildx_buildkit_default", Config: &docker.Config{
+		Image: "moby/buildkit:buildx-stable-1",
+	}}
+
+	tests := []struct {
+		config  Config
+		web     bool
+		builder bool
+	}{
+		{Config{}, true, true},
+		{Config{ExcludeImages: []string{"moby/buildkit:*"}}, true, false},
+		{Config{IncludeImages: []string{"nginx:*", "redis:*"}}, true, false},
+		{Config{IncludeLabels: []string{"com.docker.compose.project"}}, true, false},
+		{Config{IncludeLabels: []string{"tier=back"}}, false, false},
+		{Config{ExcludeLabels: []string{"tier=front"}}, false, true},
+		{Config{ExcludeNames: []string{"^buildx_"}}, true, false},
+		{Config{IncludeNames: []string{"^shop-"}}, true, false},
+		// excludes take precedence
+		{Config{IncludeNames: []string{"^shop-"}, ExcludeLabels: []string{"tier"}}, false, false},
+	}
+
+	for _, test := range tests {
+		filter, err := New(test.config)
+		if err != nil {
+			t.Fatalf("%+v: %v", test.config, err)
+		}
+		if filter.Container(web) != test.web {
+			t.Errorf("%+v: expected web to be included: %v", test.config, test.web)
+		}
+		if filter.Container(builder) != test.builder {
+			t.Errorf("%+v: expected builder to be included: %v", test.config, test.builder)
+		}
+	}
+}
+
+func TestService(t *testing.T) {
+	web := swarm.Service{}
+	web.Spec.Name = "shop_web"
+	web.Spec.Labels = map[string]string{"com.docker.stack.namespace": "shop", "tier": "front"}
+	web.Spec.TaskTemplate.ContainerSpec = &swarm.ContainerSpec{
+		Image:  "nginx:1.25@sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31",
+		Labels: map[string]string{"tier": "back", "team": "web"},
+	}
+
+	tests := []struct {
+		config   Config
+		included bool
+	}{
+		{Config{}, true},
+		{Config{IncludeImages: []string{"nginx:1.25"}}, true},
+		{Config{ExcludeImages: []string{"nginx:*"}}, false},
+		{Config{IncludeLabels: []string{"com.docker.stack.namespace=shop"}}, true},
+		// labels of the service take precedence over the ones of its containers
+		{Config{ExcludeLabels: []string{"tier=back"}}, true},
+		{Config{ExcludeLabels: []string{"team=web"}}, false},
+		{Config{ExcludeNames: []string{"^shop_"}}, false},
+	}
+
+	for _, test := range tests {
+		filter, err := New(test.config)
+		if err != nil {
+			t.Fatalf("%+v: %v", test.config, err)
+		}
+		if filter.Service(web) != test.included {
+			t.Errorf("%+v: expected web to be included: %v", test.config, test.included)
+		}
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	tests := []struct {
+		config   Config
+		network  string
+		included bool
+	}{
+		{Config{}, "bridge", true},
+		{Config{ExcludeNetworks: []string{"none"}}, "none", false},
+		{Config{ExcludeNetworks: []string{"*_internal"}}, "shop_internal", false},
+		{Config{ExcludeNetworks: []string{"*_internal"}}, "shop_default", true},
+		{Config{IncludeNetworks: []string{"shop_*"}}, "bridge", false},
+		{Config{IncludeNetworks: []string{"shop_*"}}, "shop_default", true},
+	}
+
+	for _, test := range tests {
+		filter, err := New(test.config)
+		if err != nil {
+			t.Fatalf("%+v: %v", test.config, err)
+		}
+		if filter.Network(test.network) != test.included {
+			t.Errorf("%+v: expected %s to be included: %v", test.config, test.network, test.included)
+		}
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, config := range []Config{
+		{IncludeNetworks: []string{"["}},
+		{ExcludeImages: []string{"["}},
+		{IncludeNames: []string{"("}},
+	} {
+		if _, err := New(config); err == nil {
+			t.Errorf("%+v: expected an error", config)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package dnsStorage
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// policies for names claimed by hosts of different owners
+const (
+	// ConflictAllow answers with the addresses of all hosts
+	ConflictAllow = "allow"
+	// ConflictFirst answers with the hosts of the owner which claimed the name first
+	ConflictFirst = "first"
+	// ConflictNewest answers with the hosts of the owner which claimed the name last
+	ConflictNewest = "newest"
+	// ConflictReject drops the names of a host which are claimed by another
+	// owner already, they are given to it once the other owner's hosts are gone
+	ConflictReject = "reject"
+)
+
+// Conflict is a name claimed by hosts of different owners
+type Conflict struct {
+	Name string
+	// HostIds are all hosts in the storage claiming the name
+	HostIds []string
+	// Answering are the hosts answering queries for the name
+	Answering []string
+	// Rejected are the hosts the name was dropped from
+	Rejected []string `json:",omitempty"`
+}
+
+// ValidateConflictPolicy checks that policy is one of the Conflict policies
+func ValidateConflictPolicy(policy string) error {
+	switch policy {
+	case ConflictAllow, ConflictFirst, ConflictNewest, ConflictReject:
+		return nil
+	}
+	return fmt.Errorf("unknown conflict policy %q", policy)
+}
+
+type policyRequest struct {
+	policy string
+	done   chan struct{}
+}
+
+// SetConflictPolicy sets the policy applied to names claimed by hosts of
+// different owners, e.g. containers of two compose projects of the same name.
+// The hosts in the storage are admitted again under the new policy.
+func (d *DnsStorage) SetConflictPolicy(policy string) error {
+	if err := ValidateConflictPolicy(policy); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	d.policyChannel <- policyRequest{policy: policy, done: done}
+	<-done
+	return nil
+}
+
+func (d *DnsStorage) ConflictPolicy() string {
+	d.hostsMutex.RLock()
+	defer d.hostsMutex.RUnlock()
+
+	return d.conflictPolicy
+}
+
+// owner identifies who is responsible for a host. Hosts of the same owner
+// sharing a name, e.g. replicas of a compose service, do not conflict.
+func (host Host) owner() string {
+	if host.Source != SourceContainer {
+		return host.Source
+	}
+	if host.Container != nil && host.Container.Config != nil {
+		labels := host.Container.Config.Labels
+		if project := labels["com.docker.compose.project"]; project != "" {
+			return "compose:" + project + ":" + labels["com.docker.compose.project.working_dir"]
+		}
+	}
+	if host.Container != nil {
+		return "container:" + host.Container.ID
+	}
+	return "container:" + host.Id
+}
+
+// names returns the name and aliases as lower case fqdn
+func (host Host) names() []string {
+	names := make([]string, 0, len(host.Aliases)+1)
+	for _, name := range append([]string{host.Name}, host.Aliases...) {
+		if name != "" {
+			names = append(names, strings.ToLower(dns.Fqdn(name)))
+		}
+	}
+	return names
+}
+
+// collisions returns the ids of the hosts of other owners per name of host,
+// the caller must hold the hostsMutex
+func (d *DnsStorage) collisions(host Host) map[string][]string {
+	owner := host.owner()
+	names := host.names()
+
+	collisions := make(map[string][]string)
+	for id, other := range d.hosts {
+		if id == host.Id || other.owner() == owner {
+			continue
+		}
+		for _, otherName := range other.names() {
+			for _, name := range names {
+				if name == otherName {
+					collisions[name] = append(collisions[name], id)
+				}
+			}
+		}
+	}
+	return collisions
+}
+
+// admit adds a host applying the conflict policy and returns the change to
+// publish, the caller must hold the hostsMutex
+func (d *DnsStorage) admit(host Host) *Change {
+	existing, exists := d.hosts[host.Id]
+
+	collisions := d.collisions(host)
+	for name, hostIds := range collisions {
+		d.logger.Warn("name collision", "name", name, "host", host.Id, "conflicting", hostIds, "policy", d.conflictPolicy)
+	}
+
+	admitted := host
+	if len(collisions) > 0 && d.conflictPolicy == ConflictReject {
+		d.rejected[host.Id] = host
+		admitted = withoutNames(host, collisions)
+	} else {
+		delete(d.rejected, host.Id)
+	}
+
+	if len(admitted.names()) == 0 {
+		if !exists {
+			return nil
+		}
+		delete(d.hosts, host.Id)
+		return &Change{Type: ChangeRemove, HostId: host.Id}
+	}
+
+	admitted.added = d.revision + 1
+	if exists && !existing.restored {
+		// the host keeps its claim on the names it had
+		admitted.added = existing.added
+	}
+	d.hosts[host.Id] = admitted
+
+	if exists {
+		return &Change{Type: ChangeUpdate, HostId: host.Id, Host: admitted}
+	}
+	return &Change{Type: ChangeAdd, HostId: host.Id, Host: admitted}
+}
+
+// handleConflictPolicy sets the policy and admits all hosts again in the
+// order they claimed their names, dropping the colliding names of later hosts
+// under ConflictReject and giving back dropped names otherwise
+func (d *DnsStorage) handleConflictPolicy(policy string) {
+	d.hostsMutex.Lock()
+	if policy == d.conflictPolicy {
+		d.hostsMutex.Unlock()
+		return
+	}
+	d.conflictPolicy = policy
+
+	previous := d.hosts
+	hosts := make([]Host, 0, len(previous)+len(d.rejected))
+	for id, host := range previous {
+		if rejected, ok := d.rejected[id]; ok {
+			// the host as added before dropping names
+			rejected.added, rejected.restored = host.added, host.restored
+			host = rejected
+		}
+		hosts = append(hosts, host)
+	}
+	for id, host := range d.rejected {
+		if _, ok := previous[id]; !ok {
+			// all names were dropped, it claims them last
+			host.added = math.MaxUint64
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].added != hosts[j].added {
+			return hosts[i].added < hosts[j].added
+		}
+		return hosts[i].Id < hosts[j].Id
+	})
+
+	d.hosts, d.rejected = make(Hosts, len(previous)), make(Hosts)
+	var changes []Change
+	for _, host := range hosts {
+		d.admit(host)
+		admitted, ok := d.hosts[host.Id]
+		existing, existed := previous[host.Id]
+		switch {
+		case ok && existed:
+			admitted.added = existing.added
+			d.hosts[host.Id] = admitted
+			if admitted.Name != existing.Name || !slices.Equal(admitted.Aliases, existing.Aliases) {
+				changes = append(changes, Change{Type: ChangeUpdate, HostId: host.Id, Host: admitted})
+			}
+		case ok:
+			changes = append(changes, Change{Type: ChangeAdd, HostId: host.Id, Host: admitted})
+		case existed:
+			changes = append(changes, Change{Type: ChangeRemove, HostId: host.Id})
+		}
+	}
+	d.hostsMutex.Unlock()
+
+	for i := range changes {
+		d.commit(&changes[i])
+	}
+}
+
+// withoutNames returns host without the given names, the first name left
+// becomes its name
+func withoutNames(host Host, names map[string][]string) Host {
+	var kept []string
+	for _, name := range append([]string{host.Name}, host.Aliases...) {
+		if _, drop := names[strings.ToLower(dns.Fqdn(name))]; name != "" && !drop {
+			kept = append(kept, name)
+		}
+	}
+
+	host.Name, host.Aliases = "", nil
+	if len(kept) > 0 {
+		host.Name, host.Aliases = kept[0], kept[1:]
+	}
+	return host
+}
+
+// rejectedFor returns the ids of the rejected hosts claiming any of names,
+// sorted to readmit them in a stable order, the caller must hold the hostsMutex
+func (d *DnsStorage) rejectedFor(names []string) (ids []string) {
+	for id, host := range d.rejected {
+		for _, name := range host.names() {
+			if slices.Contains(names, name) {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+	return
+}
+
+// applyConflictPolicy reduces the hosts found for a name to the answering
+// ones, the caller must hold the hostsMutex
+func (d *DnsStorage) applyConflictPolicy(hosts []Host) []Host {
+	if len(hosts) < 2 || (d.conflictPolicy != ConflictFirst && d.conflictPolicy != ConflictNewest) {
+		return hosts
+	}
+
+	// revision at which each owner claimed the name
+	claimed := make(map[string]uint64)
+	for _, host := range hosts {
+		owner := host.owner()
+		if rev, ok := claimed[owner]; !ok || host.added < rev {
+			claimed[owner] = host.added
+		}
+	}
+	if len(claimed) < 2 {
+		return hosts
+	}
+
+	var winner string
+	for owner, rev := range claimed {
+		if winner == "" ||
+			(d.conflictPolicy == ConflictFirst && (rev < claimed[winner] || rev == claimed[winner] && owner < winner)) ||
+			(d.conflictPolicy == ConflictNewest && (rev > claimed[winner] || rev == claimed[winner] && owner < winner)) {
+			winner = owner
+		}
+	}
+
+	answering := make([]Host, 0, len(hosts))
+	for _, host := range hosts {
+		if host.owner() == winner {
+			answering = append(answering, host)
+		}
+	}
+	return answering
+}
+
+// Conflicts returns all names claimed by hosts of different owners, sorted by name
+func (d *DnsStorage) Conflicts() []Conflict {
+	d.hostsMutex.RLock()
+	defer d.hostsMutex.RUnlock()
+
+	byName := make(map[string][]Host)
+	for _, host := range d.hosts {
+		for _, name := range host.names() {
+			byName[name] = append(byName[name], host)
+		}
+	}
+
+	// names of the rejected hosts which were dropped
+	rejectedByName := make(map[string][]string)
+	for id, host := range d.rejected {
+		admitted := d.hosts[id].names()
+		for _, name := range host.names() {
+			if !slices.Contains(admitted, name) {
+				rejectedByName[name] = append(rejectedByName[name], id)
+			}
+		}
+	}
+
+	conflicts := []Conflict{}
+	for name, hosts := range byName {
+		owners := make(map[string]bool)
+		for _, host := range hosts {
+			owners[host.owner()] = true
+		}
+		if len(owners) < 2 && len(rejectedByName[name]) == 0 {
+			continue
+		}
+
+		conflict := Conflict{Name: name, Rejected: rejectedByName[name]}
+		for _, host := range hosts {
+			conflict.HostIds = append(conflict.HostIds, host.Id)
+		}
+		for _, host := range d.applyConflictPolicy(hosts) {
+			conflict.Answering = append(conflict.Answering, host.Id)
+		}
+		sort.Strings(conflict.HostIds)
+		sort.Strings(conflict.Answering)
+		sort.Strings(conflict.Rejected)
+		conflicts = append(conflicts, conflict)
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Name < conflicts[j].Name
+	})
+	return conflicts
+}
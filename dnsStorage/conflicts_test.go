@@ -0,0 +1,218 @@
+package dnsStorage
+
+import (
+	"log/slog"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+func composeHost(id, project, workingDir, address string) Host {
+	return Host{
+		Id:      id,
+		Name:    "web." + project + ".docker",
+		Aliases: []string{project + ".docker"},
+		Address: net.ParseIP(address),
+		Source:  SourceContainer,
+		Container: &docker.Container{ID: id, Config: &docker.Config{Labels: map[string]string{
+			"com.docker.compose.project":             project,
+			"com.docker.compose.project.working_dir": workingDir,
+		}}},
+	}
+}
+
+func TestConflictPolicies(t *testing.T) {
+	tests := []struct {
+		policy    string
+		answering []string
+		rejected  []string
+	}{
+		{ConflictAllow, []string{"first", "replica", "second"}, nil},
+		{ConflictFirst, []string{"first", "replica"}, nil},
+		{ConflictNewest, []string{"second"}, nil},
+		{ConflictReject, []string{"first", "replica"}, []string{"second"}},
+	}
+
+	for _, test := range tests {
+		storage := NewDnsStorage(slog.Default())
+		if err := storage.SetConflictPolicy(test.policy); err != nil {
+			t.Fatal(err)
+		}
+		subscription := storage.Subscribe()
+
+		// the replica belongs to the same checkout and does not conflict
+		storage.AddHost(composeHost("first", "shop", "/home/a/shop", "10.0.0.1"))
+		nextChange(t, subscription)
+		storage.AddHost(composeHost("replica", "shop", "/home/a/shop", "10.0.0.2"))
+		nextChange(t, subscription)
+		storage.AddHost(composeHost("second", "shop", "/home/b/shop", "10.0.0.3"))
+		if test.policy != ConflictReject {
+			nextChange(t, subscription)
+		}
+		// wait for the last add to be processed
+		storage.AddHost(Host{Id: "other", Name: "other.docker", Source: SourceStatic})
+		nextChange(t, subscription)
+
+		var answering []string
+		for _, host := range storage.FindHosts("web.shop.docker.") {
+			answering = append(answering, host.Id)
+		}
+		sort.Strings(answering)
+		if !reflect.DeepEqual(answering, test.answering) {
+			t.Errorf("%s: expected %v to answer, got %v", test.policy, test.answering, answering)
+		}
+
+		conflicts := storage.Conflicts()
+		if len(conflicts) != 2 || conflicts[0].Name != "shop.docker." || conflicts[1].Name != "web.shop.docker." {
+			t.Fatalf("%s: unexpected conflicts %+v", test.policy, conflicts)
+		}
+		if !reflect.DeepEqual(conflicts[1].Answering, test.answering) || !reflect.DeepEqual(conflicts[1].Rejected, test.rejected) {
+			t.Errorf("%s: unexpected conflict %+v", test.policy, conflicts[1])
+		}
+
+		storage.Close()
+	}
+
+	if err := NewDnsStorage(slog.Default()).SetConflictPolicy("bogus"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestRejectDropsCollidingNames(t *testing.T) {
+	storage := NewDnsStorage(slog.Default())
+	defer storage.Close()
+	if err := storage.SetConflictPolicy(ConflictReject); err != nil {
+		t.Fatal(err)
+	}
+	subscription := storage.Subscribe()
+
+	// both projects have a web service claiming the service alias
+	shop := composeHost("shop", "shop", "/home/a/shop", "10.0.0.1")
+	shop.Aliases = []string{"web.docker"}
+	blog := composeHost("blog", "blog", "/home/a/blog", "10.0.0.2")
+	blog.Aliases = []string{"web.docker"}
+
+	storage.AddHost(shop)
+	nextChange(t, subscription)
+	storage.AddHost(blog)
+	if change := nextChange(t, subscription); change.Type != ChangeAdd || change.Host.Name != "web.blog.docker" || len(change.Host.Aliases) != 0 {
+		t.Fatalf("expected blog to be added without the colliding alias, got %+v", change)
+	}
+
+	ids := func(name string) (ids []string) {
+		for _, host := range storage.FindHosts(name) {
+			ids = append(ids, host.Id)
+		}
+		return
+	}
+	if hosts := ids("web.blog.docker."); !reflect.DeepEqual(hosts, []string{"blog"}) {
+		t.Errorf("expected blog to keep its own name, got %v", hosts)
+	}
+	if hosts := ids("web.docker."); !reflect.DeepEqual(hosts, []string{"shop"}) {
+		t.Errorf("expected shop to answer for the alias, got %v", hosts)
+	}
+
+	conflicts := storage.Conflicts()
+	if len(conflicts) != 1 || conflicts[0].Name != "web.docker." || !reflect.DeepEqual(conflicts[0].Rejected, []string{"blog"}) {
+		t.Errorf("unexpected conflicts %+v", conflicts)
+	}
+
+	// the alias is given to blog once shop is gone
+	storage.RemoveHost("shop")
+	if change := nextChange(t, subscription); change.Type != ChangeRemove || change.HostId != "shop" {
+		t.Fatalf("expected shop to be removed, got %+v", change)
+	}
+	if change := nextChange(t, subscription); change.Type != ChangeUpdate || change.HostId != "blog" {
+		t.Fatalf("expected blog to be updated, got %+v", change)
+	}
+	if hosts := ids("web.docker."); !reflect.DeepEqual(hosts, []string{"blog"}) {
+		t.Errorf("expected blog to answer for the alias, got %v", hosts)
+	}
+	if conflicts := storage.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("expected no conflicts, got %+v", conflicts)
+	}
+}
+
+func TestRejectReadmitsHost(t *testing.T) {
+	storage := NewDnsStorage(slog.Default())
+	defer storage.Close()
+	if err := storage.SetConflictPolicy(ConflictReject); err != nil {
+		t.Fatal(err)
+	}
+	subscription := storage.Subscribe()
+
+	// all names of the second checkout collide
+	storage.AddHost(composeHost("first", "shop", "/home/a/shop", "10.0.0.1"))
+	nextChange(t, subscription)
+	storage.AddHost(composeHost("second", "shop", "/home/b/shop", "10.0.0.2"))
+	storage.RemoveHost("first")
+	if change := nextChange(t, subscription); change.Type != ChangeRemove || change.HostId != "first" {
+		t.Fatalf("expected first to be removed, got %+v", change)
+	}
+	if change := nextChange(t, subscription); change.Type != ChangeAdd || change.HostId != "second" {
+		t.Fatalf("expected second to be added, got %+v", change)
+	}
+
+	if addrs := storage.FindHostAddresses("web.shop.docker."); len(addrs) != 1 || !addrs[0].Equal(net.ParseIP("10.0.0.2")) {
+		t.Errorf("expected the address of second, got %v", addrs)
+	}
+}
+
+func TestConflictPolicyReload(t *testing.T) {
+	storage := NewDnsStorage(slog.Default())
+	defer storage.Close()
+	subscription := storage.Subscribe()
+
+	shop := composeHost("shop", "shop", "/home/a/shop", "10.0.0.1")
+	shop.Aliases = []string{"web.docker"}
+	blog := composeHost("blog", "blog", "/home/a/blog", "10.0.0.2")
+	blog.Aliases = []string{"web.docker"}
+	for _, host := range []Host{shop, blog} {
+		storage.AddHost(host)
+		nextChange(t, subscription)
+	}
+
+	ids := func(name string) (ids []string) {
+		for _, host := range storage.FindHosts(name) {
+			ids = append(ids, host.Id)
+		}
+		sort.Strings(ids)
+		return
+	}
+
+	// the later host loses the colliding alias
+	if err := storage.SetConflictPolicy(ConflictReject); err != nil {
+		t.Fatal(err)
+	}
+	if change := nextChange(t, subscription); change.Type != ChangeUpdate || change.HostId != "blog" || len(change.Host.Aliases) != 0 {
+		t.Fatalf("expected blog to be updated without the alias, got %+v", change)
+	}
+	if hosts := ids("web.docker."); !reflect.DeepEqual(hosts, []string{"shop"}) {
+		t.Errorf("expected shop to answer for the alias, got %v", hosts)
+	}
+
+	// a host with all names colliding is left out
+	storage.AddHost(composeHost("checkout", "shop", "/home/b/shop", "10.0.0.3"))
+
+	// and everything is given back without the policy
+	if err := storage.SetConflictPolicy(ConflictAllow); err != nil {
+		t.Fatal(err)
+	}
+	changes := map[string]string{}
+	for i := 0; i < 2; i++ {
+		change := nextChange(t, subscription)
+		changes[change.HostId] = change.Type
+	}
+	if expected := map[string]string{"blog": ChangeUpdate, "checkout": ChangeAdd}; !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected changes %v, got %v", expected, changes)
+	}
+	if hosts := ids("web.docker."); !reflect.DeepEqual(hosts, []string{"blog", "shop"}) {
+		t.Errorf("expected both hosts to answer for the alias, got %v", hosts)
+	}
+	if conflicts := storage.Conflicts(); len(conflicts) != 2 {
+		t.Errorf("expected 2 conflicts, got %+v", conflicts)
+	}
+}
@@ -2,8 +2,10 @@ package dnsStorage
 
 import (
 	"github.com/fsouza/go-dockerclient"
+	"log/slog"
 	"net"
 	"sync"
+	"time"
 )
 
 type Host struct {
@@ -12,6 +14,19 @@ type Host struct {
 	Name             string
 	Aliases          []string
 	Container        *docker.Container
+	// Network is the docker network the address belongs to
+	Network string
+	// Source is one of SourceContainer, SourceStatic, SourceApi or SourceSwarm
+	Source string
+	// Target is the canonical name of CNAME records
+	Target string
+	// Text holds the strings of TXT records
+	Text []string
+
+	// restored is set for hosts loaded from a snapshot until they are added again
+	restored bool
+	// added is the revision at which the host was added
+	added uint64
 }
 
 type Hosts map[string]Host
@@ -21,40 +36,70 @@ type DnsStorage struct {
 	hosts      Hosts
 	hostsMutex sync.RWMutex
 
+	// conflicts of names between hosts of different owners, guarded by
+	// hostsMutex and only changed by MainRoutine
+	conflictPolicy string
+	rejected       Hosts
+
+	// snapshot file, empty if not persisted
+	persistPath string
+	// unsaved changes and the pending save, only accessed by MainRoutine
+	dirty     bool
+	saveTimer <-chan time.Time
+
+	logger *slog.Logger
+
+	// change tracking, only accessed by MainRoutine
+	revision  uint64
+	changelog []Change
+
 	// subscription management
 	subscriptions map[*Subscription]bool
+	// statistics, accessed atomically
+	subscriberCount int64
+	resumeCount     int64
 
-	// communication channels
-	subscribeChannel   chan *Subscription
+	// communication channels, adds and removes share the hostChannel to be
+	// applied in order
+	subscribeChannel   chan subscribeRequest
 	unsubscribeChannel chan *Subscription
-	addHostChannel     chan Host
-	removeHostChannel  chan string
+	hostChannel        chan hostOperation
+	policyChannel      chan policyRequest
+	pruneChannel       chan string
+	closeChannel       chan struct{}
+	stopped            chan struct{}
 }
 
-type Subscription struct {
-	OnAdd    chan Host
-	OnRemove chan string
+func NewDnsStorage(logger *slog.Logger) (dnsStorage *DnsStorage) {
+	dnsStorage = newDnsStorage(logger)
+
+	go dnsStorage.MainRoutine()
+
+	return
 }
 
-func NewDnsStorage() (dnsStorage *DnsStorage) {
-	dnsStorage = &DnsStorage{
+func newDnsStorage(logger *slog.Logger) *DnsStorage {
+	return &DnsStorage{
+		logger:             logger.With("component", "dnsStorage"),
 		hosts:              make(Hosts),
+		conflictPolicy:     ConflictAllow,
+		rejected:           make(Hosts),
 		subscriptions:      make(map[*Subscription]bool),
-		subscribeChannel:   make(chan *Subscription),
+		revision:           initialRevision(),
+		subscribeChannel:   make(chan subscribeRequest),
 		unsubscribeChannel: make(chan *Subscription),
-		addHostChannel:     make(chan Host, 4),
-		removeHostChannel:  make(chan string, 16),
+		hostChannel:        make(chan hostOperation, 16),
+		policyChannel:      make(chan policyRequest),
+		pruneChannel:       make(chan string),
+		closeChannel:       make(chan struct{}),
+		stopped:            make(chan struct{}),
 	}
-
-	go dnsStorage.MainRoutine()
-
-	return
 }
 
 func (d *DnsStorage) AddHost(host Host) {
-	d.addHostChannel <- host
+	d.hostChannel <- hostOperation{host: host}
 }
 
 func (d *DnsStorage) RemoveHost(id string) {
-	d.removeHostChannel <- id
+	d.hostChannel <- hostOperation{remove: true, host: Host{Id: id}}
 }
@@ -0,0 +1,149 @@
+package dnsStorage
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// changes within this delay are saved to the snapshot at once
+const saveDelay = time.Second
+
+type snapshot struct {
+	Hosts Hosts
+}
+
+// NewPersistentDnsStorage creates a storage which is saved to path shortly
+// after changes and when it is closed. A snapshot previously saved there is restored, so names resolve
+// immediately after a restart. Restored hosts are replaced when added again
+// and can be dropped using PruneRestored once the sources are re-synced.
+func NewPersistentDnsStorage(path string, logger *slog.Logger) (dnsStorage *DnsStorage, err error) {
+	dnsStorage = newDnsStorage(logger)
+	dnsStorage.persistPath = path
+
+	if err = dnsStorage.load(); err != nil {
+		return nil, err
+	}
+
+	go dnsStorage.MainRoutine()
+
+	return
+}
+
+// IsRestored reports whether the host was restored from the snapshot and not
+// added again since
+func (h Host) IsRestored() bool {
+	return h.restored
+}
+
+func (d *DnsStorage) IsPersistent() bool {
+	return d.persistPath != ""
+}
+
+// PruneRestored removes all hosts of the given source which were restored from
+// the snapshot but not added again since. Hosts added before this call are
+// guaranteed to be kept.
+func (d *DnsStorage) PruneRestored(source string) {
+	d.pruneChannel <- source
+}
+
+func (d *DnsStorage) load() error {
+	data, err := os.ReadFile(d.persistPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	for id, host := range s.Hosts {
+		host.restored = true
+		d.hosts[id] = host
+	}
+	d.logger.Info("restored hosts from snapshot", "count", len(s.Hosts), "path", d.persistPath)
+	return nil
+}
+
+// markDirty schedules a save of the snapshot, only called by MainRoutine
+func (d *DnsStorage) markDirty() {
+	if d.persistPath == "" {
+		return
+	}
+
+	d.dirty = true
+	if d.saveTimer == nil {
+		d.saveTimer = time.After(saveDelay)
+	}
+}
+
+// flush saves the snapshot if it changed, only called by MainRoutine
+func (d *DnsStorage) flush() {
+	d.saveTimer = nil
+	if d.dirty {
+		d.dirty = false
+		d.save()
+	}
+}
+
+// persistedContainer reduces a container to what identifies its owner and is
+// shown by the api, leaving out e.g. its mounts and the host configuration
+func persistedContainer(container *docker.Container) *docker.Container {
+	if container == nil {
+		return nil
+	}
+
+	reduced := &docker.Container{
+		ID:      container.ID,
+		Name:    container.Name,
+		Created: container.Created,
+		Image:   container.Image,
+	}
+	if container.Config != nil {
+		reduced.Config = &docker.Config{
+			Image:  container.Config.Image,
+			Labels: container.Config.Labels,
+		}
+	}
+	return reduced
+}
+
+func (d *DnsStorage) save() {
+	hosts := d.GetHosts()
+	for id, host := range hosts {
+		host.Container = persistedContainer(host.Container)
+		hosts[id] = host
+	}
+
+	data, err := json.Marshal(snapshot{Hosts: hosts})
+	if err != nil {
+		d.logger.Error("could not encode snapshot", "err", err)
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(d.persistPath), "."+filepath.Base(d.persistPath)+".")
+	if err != nil {
+		d.logger.Error("could not save snapshot", "path", d.persistPath, "err", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), d.persistPath)
+	}
+	if err != nil {
+		d.logger.Error("could not save snapshot", "path", d.persistPath, "err", err)
+	}
+}
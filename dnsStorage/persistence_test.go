@@ -0,0 +1,97 @@
+package dnsStorage
+
+import (
+	"log/slog"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := NewPersistentDnsStorage(path, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscription := storage.Subscribe()
+	storage.AddHost(Host{Id: "a", Name: "a.docker", Address: net.ParseIP("10.0.0.1"), Source: SourceContainer})
+	storage.AddHost(Host{Id: "b", Name: "b.docker", Address: net.ParseIP("10.0.0.2"), Source: SourceApi})
+	for i := 0; i < 2; i++ {
+		select {
+		case <-subscription.Changes:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for add")
+		}
+	}
+
+	// restart, closing saves pending changes
+	storage.Close()
+	restored, err := NewPersistentDnsStorage(path, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addrs := restored.FindHostAddresses("a.docker."); len(addrs) != 1 || !addrs[0].Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("expected restored address for a.docker, got %v", addrs)
+	}
+
+	subscription = restored.Subscribe()
+	restored.PruneRestored(SourceContainer)
+	select {
+	case change := <-subscription.Changes:
+		if change.Type != ChangeRemove || change.HostId != "a" {
+			t.Errorf("expected a to be pruned, got %s of %s", change.Type, change.HostId)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for remove")
+	}
+
+	if _, ok := restored.GetHost("b"); !ok {
+		t.Error("expected api host b to survive pruning of containers")
+	}
+}
+
+func TestPersistenceSavesOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := NewPersistentDnsStorage(path, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.AddHost(Host{
+		Id:      "web_bridge",
+		Name:    "web.docker",
+		Address: net.ParseIP("10.0.0.1"),
+		Source:  SourceContainer,
+		Container: &docker.Container{
+			ID:         "5f2bd1c9e5a0",
+			Name:       "/web",
+			Config:     &docker.Config{Image: "nginx", Labels: map[string]string{"com.docker.compose.project": "shop"}, Env: []string{"SECRET=1"}},
+			HostConfig: &docker.HostConfig{NetworkMode: "bridge"},
+		},
+	})
+
+	// saves are delayed, closing must not lose the change
+	storage.Close()
+
+	restored, err := NewPersistentDnsStorage(path, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+
+	host, ok := restored.GetHost("web_bridge")
+	if !ok {
+		t.Fatal("expected web_bridge to be restored")
+	}
+	if host.Container.ID != "5f2bd1c9e5a0" || host.Container.Config.Labels["com.docker.compose.project"] != "shop" {
+		t.Errorf("expected the container to be identified, got %+v", host.Container)
+	}
+	if host.Container.HostConfig != nil || host.Container.Config.Env != nil {
+		t.Errorf("expected only the needed container fields to be saved, got %+v", host.Container)
+	}
+}
@@ -7,21 +7,37 @@ import (
 )
 
 func (d *DnsStorage) FindHostAddresses(name string) (addrs []net.IP) {
+	for _, host := range d.FindHosts(name) {
+		if host.Address != nil {
+			addrs = append(addrs, host.Address)
+		}
+	}
+	return
+}
+
+// FindHosts returns all hosts answering for the given name or alias
+func (d *DnsStorage) FindHosts(name string) (hosts []Host) {
 	d.hostsMutex.RLock()
 	defer d.hostsMutex.RUnlock()
 
 	for _, host := range d.hosts {
-		if dns.Fqdn(host.Name) == name {
-			addrs = append(addrs, host.Address)
+		if host.hasName(name) {
+			hosts = append(hosts, host)
 		}
+	}
+	return d.applyConflictPolicy(hosts)
+}
 
-		for _, alias := range host.Aliases {
-			if dns.Fqdn(alias) == name {
-				addrs = append(addrs, host.Address)
-			}
+func (host Host) hasName(name string) bool {
+	if dns.Fqdn(host.Name) == name {
+		return true
+	}
+	for _, alias := range host.Aliases {
+		if dns.Fqdn(alias) == name {
+			return true
 		}
 	}
-	return
+	return false
 }
 
 func (d *DnsStorage) FindReverseHost(address string) (hosts []string) {
@@ -31,6 +47,9 @@ func (d *DnsStorage) FindReverseHost(address string) (hosts []string) {
 	address = strings.ToLower(dns.Fqdn(address))
 
 	for _, entry := range d.hosts {
+		if entry.Address == nil {
+			continue
+		}
 		if r, _ := dns.ReverseAddr(entry.Address.String()); address == r {
 			hosts = append(hosts, dns.Fqdn(entry.Name))
 		}
@@ -38,6 +57,21 @@ func (d *DnsStorage) FindReverseHost(address string) (hosts []string) {
 	return
 }
 
+func (d *DnsStorage) GetHost(id string) (host Host, ok bool) {
+	d.hostsMutex.RLock()
+	defer d.hostsMutex.RUnlock()
+
+	host, ok = d.hosts[id]
+	return
+}
+
+func (d *DnsStorage) HostCount() int {
+	d.hostsMutex.RLock()
+	defer d.hostsMutex.RUnlock()
+
+	return len(d.hosts)
+}
+
 func (d *DnsStorage) GetHosts() (hosts Hosts) {
 	d.hostsMutex.RLock()
 	defer d.hostsMutex.RUnlock()
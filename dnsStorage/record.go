@@ -0,0 +1,69 @@
+package dnsStorage
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// sources of hosts
+const (
+	SourceContainer = "container"
+	SourceStatic    = "static"
+	SourceApi       = "api"
+	SourceSwarm     = "swarm"
+)
+
+// Record describes a manually created record, either loaded from the
+// configuration or created through the api.
+type Record struct {
+	Name string
+	// Type is one of A, AAAA, CNAME or TXT
+	Type string
+	// Value is the address for A and AAAA, the target name for CNAME and the text for TXT records
+	Value string
+}
+
+// Id returns an identifier which is the same for identical records
+func (r Record) Id(source string) string {
+	sum := sha1.Sum([]byte(strings.ToLower(r.Name) + "\x00" + strings.ToUpper(r.Type) + "\x00" + r.Value))
+	return source + "_" + hex.EncodeToString(sum[:6])
+}
+
+// Host converts the record into a host of the given source
+func (r Record) Host(source string) (host Host, err error) {
+	host = Host{
+		Id:     r.Id(source),
+		Name:   strings.ToLower(r.Name),
+		Source: source,
+	}
+
+	if host.Name == "" {
+		return host, fmt.Errorf("record without name")
+	}
+
+	switch strings.ToUpper(r.Type) {
+	case "A":
+		if host.Address = net.ParseIP(r.Value).To4(); host.Address == nil {
+			return host, fmt.Errorf("invalid ipv4 address for %s: %q", r.Name, r.Value)
+		}
+	case "AAAA":
+		host.Address = net.ParseIP(r.Value)
+		if host.Address == nil || host.Address.To4() != nil {
+			return host, fmt.Errorf("invalid ipv6 address for %s: %q", r.Name, r.Value)
+		}
+	case "CNAME":
+		if r.Value == "" {
+			return host, fmt.Errorf("empty target for %s", r.Name)
+		}
+		host.Target = strings.ToLower(r.Value)
+	case "TXT":
+		host.Text = []string{r.Value}
+	default:
+		return host, fmt.Errorf("unsupported record type for %s: %q", r.Name, r.Type)
+	}
+
+	return host, nil
+}
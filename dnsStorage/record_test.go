@@ -0,0 +1,43 @@
+package dnsStorage
+
+import "testing"
+
+func TestRecordHost(t *testing.T) {
+	tests := []struct {
+		record  Record
+		valid   bool
+		address string
+		target  string
+	}{
+		{Record{"host.docker", "A", "10.0.0.1"}, true, "10.0.0.1", ""},
+		{Record{"host.docker", "a", "10.0.0.1"}, true, "10.0.0.1", ""},
+		{Record{"host.docker", "A", "fd00::1"}, false, "", ""},
+		{Record{"host.docker", "AAAA", "fd00::1"}, true, "fd00::1", ""},
+		{Record{"host.docker", "AAAA", "10.0.0.1"}, false, "", ""},
+		{Record{"db.docker", "CNAME", "Postgres.Shared.docker"}, true, "", "postgres.shared.docker"},
+		{Record{"db.docker", "CNAME", ""}, false, "", ""},
+		{Record{"db.docker", "TXT", "some text"}, true, "", ""},
+		{Record{"db.docker", "MX", "mail.docker"}, false, "", ""},
+		{Record{"", "A", "10.0.0.1"}, false, "", ""},
+	}
+
+	for _, test := range tests {
+		host, err := test.record.Host(SourceStatic)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: expected valid=%v, got err=%v", test.record, test.valid, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		if host.Source != SourceStatic || host.Id != test.record.Id(SourceStatic) {
+			t.Errorf("%v: unexpected source/id %s/%s", test.record, host.Source, host.Id)
+		}
+		if test.address != "" && host.Address.String() != test.address {
+			t.Errorf("%v: expected address %s, got %s", test.record, test.address, host.Address)
+		}
+		if host.Target != test.target {
+			t.Errorf("%v: expected target %s, got %s", test.record, test.target, host.Target)
+		}
+	}
+}
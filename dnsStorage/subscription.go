@@ -1,66 +1,302 @@
 package dnsStorage
 
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// number of changes kept in the changelog for resuming subscriptions
+const changelogSize = 1024
+
+// number of changes buffered per subscription before it is dropped
+const subscriptionBuffer = 256
+
+// types of changes
+const (
+	ChangeAdd    = "add"
+	ChangeUpdate = "update"
+	ChangeRemove = "remove"
+)
+
+// Change is a single mutation of the storage.
+type Change struct {
+	// Revision is increased by one for every change
+	Revision uint64
+	// Type is one of ChangeAdd, ChangeUpdate or ChangeRemove
+	Type   string
+	HostId string
+	// Host is empty for removes
+	Host Host
+}
+
+// Subscription is the consistent state of the storage at the time of
+// subscribing plus a stream of all subsequent changes.
+type Subscription struct {
+	// Snapshot holds all hosts at Revision. It is nil if the subscription was
+	// resumed from the changelog.
+	Snapshot Hosts
+	// Revision is the revision of the snapshot or of the last backlog entry
+	Revision uint64
+	// Backlog holds the changes after the requested revision when resumed
+	Backlog []Change
+	// Changes receives all changes after Revision. It is closed when the
+	// subscriber does not keep up, unsubscribes or the storage is closed; a
+	// subscriber may then resume using the revision of the last change received.
+	Changes chan Change
+}
+
+// hostOperation adds host or removes the host of its id
+type hostOperation struct {
+	remove bool
+	host   Host
+}
+
+type subscribeRequest struct {
+	since uint64
+	reply chan *Subscription
+}
+
+// initialRevision lets revisions start at the startup time in microseconds,
+// so they keep increasing across restarts and resuming clients of a previous
+// process get a fresh snapshot instead of a wrong backlog
+func initialRevision() uint64 {
+	return uint64(time.Now().UnixNano() / 1000)
+}
+
 func (d *DnsStorage) MainRoutine() {
 	for {
 		select {
-		case s := <-d.subscribeChannel:
-			d.subscriptions[s] = true
+		case r := <-d.subscribeChannel:
+			r.reply <- d.handleSubscribe(r.since)
 		case s := <-d.unsubscribeChannel:
-			delete(d.subscriptions, s)
-		case newHost := <-d.addHostChannel:
-			d.handleAddHost(newHost)
-		case hostId := <-d.removeHostChannel:
-			d.handleRemoveHost(hostId)
+			d.handleUnsubscribe(s)
+		case op := <-d.hostChannel:
+			d.handleHostOperation(op)
+		case r := <-d.policyChannel:
+			d.drainPending()
+			d.handleConflictPolicy(r.policy)
+			close(r.done)
+		case source := <-d.pruneChannel:
+			d.drainPending()
+			d.handlePruneRestored(source)
+		case <-d.saveTimer:
+			d.flush()
+		case <-d.closeChannel:
+			d.drainPending()
+			d.flush()
+			for s := range d.subscriptions {
+				d.handleUnsubscribe(s)
+			}
+			close(d.stopped)
+			return
 		}
 	}
 }
 
-func (d *DnsStorage) Subscribe() (s *Subscription) {
-	s = &Subscription{
-		OnAdd:    make(chan Host, 4),
-		OnRemove: make(chan string, 4),
-	}
-	d.subscribeChannel <- s;
-	return
+// Close applies pending changes, closes all subscriptions and stops the
+// MainRoutine. The storage must neither be changed nor subscribed to afterwards.
+func (d *DnsStorage) Close() {
+	close(d.closeChannel)
+	<-d.stopped
+}
+
+// Subscribe returns a snapshot of all hosts and subscribes to all changes after it.
+func (d *DnsStorage) Subscribe() *Subscription {
+	return d.SubscribeSince(0)
+}
+
+// SubscribeSince resumes a subscription after the given revision. If the
+// changes since are no longer available (or revision is 0), a snapshot is
+// returned instead.
+func (d *DnsStorage) SubscribeSince(revision uint64) *Subscription {
+	reply := make(chan *Subscription)
+	d.subscribeChannel <- subscribeRequest{since: revision, reply: reply}
+	return <-reply
 }
 
 func (d *DnsStorage) Unsubscribe(s *Subscription) {
-	close(s.OnAdd)
-	close(s.OnRemove)
-	d.unsubscribeChannel <- s;
+	d.unsubscribeChannel <- s
+}
+
+// SubscriberCount returns the number of active subscriptions
+func (d *DnsStorage) SubscriberCount() int {
+	return int(atomic.LoadInt64(&d.subscriberCount))
+}
+
+// ResumeCount returns how many subscriptions asked to resume after a revision
+func (d *DnsStorage) ResumeCount() int {
+	return int(atomic.LoadInt64(&d.resumeCount))
 }
 
+func (d *DnsStorage) handleSubscribe(since uint64) *Subscription {
+	s := &Subscription{
+		Revision: d.revision,
+		Changes:  make(chan Change, subscriptionBuffer),
+	}
+	d.subscriptions[s] = true
+	atomic.AddInt64(&d.subscriberCount, 1)
+	if since != 0 {
+		atomic.AddInt64(&d.resumeCount, 1)
+	}
+
+	if since != 0 && since <= d.revision &&
+		(since == d.revision || (len(d.changelog) > 0 && d.changelog[0].Revision <= since+1)) {
+		for _, change := range d.changelog {
+			if change.Revision > since {
+				s.Backlog = append(s.Backlog, change)
+			}
+		}
+		return s
+	}
+
+	s.Snapshot = d.GetHosts()
+	return s
+}
+
+func (d *DnsStorage) handleUnsubscribe(s *Subscription) {
+	if d.subscriptions[s] {
+		delete(d.subscriptions, s)
+		atomic.AddInt64(&d.subscriberCount, -1)
+		close(s.Changes)
+	}
+}
+
+func (d *DnsStorage) handleHostOperation(op hostOperation) {
+	if op.remove {
+		d.handleRemoveHost(op.host.Id)
+	} else {
+		d.handleAddHost(op.host)
+	}
+}
+
+// handleAddHost adds a host or replaces the host of the same id if it changed
 func (d *DnsStorage) handleAddHost(host Host) {
-	// add to hosts list
 	d.hostsMutex.Lock()
-	_, exists := d.hosts[host.Id]
-	if exists {
+	existing, exists := d.hosts[host.Id]
+	if rejected, ok := d.rejected[host.Id]; ok {
+		// compare with the host as added before dropping names
+		existing = rejected
+	}
+	if exists && !existing.restored && !changed(existing, host) {
 		d.hostsMutex.Unlock()
 		return
 	}
 
-	d.hosts[host.Id] = host
+	change := d.admit(host)
 	d.hostsMutex.Unlock()
-
-	// publish to subscribes
-	for subscription, _ := range d.subscriptions {
-		subscription.OnAdd <- host
-	}
+	d.commit(change)
 }
 
 func (d *DnsStorage) handleRemoveHost(hostId string) {
 	d.hostsMutex.Lock()
-	_, exists := d.hosts[hostId]
+	delete(d.rejected, hostId)
+	host, exists := d.hosts[hostId]
 	if !exists {
 		d.hostsMutex.Unlock()
 		return
 	}
 
 	delete(d.hosts, hostId)
+	readmit := d.rejectedFor(host.names())
 	d.hostsMutex.Unlock()
+	d.commit(&Change{Type: ChangeRemove, HostId: hostId})
+
+	// names freed by the host are given to the hosts they were dropped from
+	for _, id := range readmit {
+		d.hostsMutex.Lock()
+		var change *Change
+		if rejected, ok := d.rejected[id]; ok {
+			change = d.admit(rejected)
+		}
+		d.hostsMutex.Unlock()
+		d.commit(change)
+	}
+}
 
-	// publish to subscribes
-	for subscription, _ := range d.subscriptions {
-		subscription.OnRemove <- hostId
+// changed reports whether the records of a host or the details of its
+// container shown by the api differ
+func changed(existing, host Host) bool {
+	return !existing.Address.Equal(host.Address) ||
+		existing.Name != host.Name ||
+		!slices.Equal(existing.Aliases, host.Aliases) ||
+		existing.Network != host.Network ||
+		existing.Source != host.Source ||
+		existing.Target != host.Target ||
+		!slices.Equal(existing.Text, host.Text) ||
+		containerChanged(existing.Container, host.Container)
+}
+
+func containerChanged(existing, container *docker.Container) bool {
+	if existing == nil || container == nil {
+		return existing != container
+	}
+	if existing.ID != container.ID || existing.Name != container.Name || existing.Image != container.Image ||
+		!existing.Created.Equal(container.Created) || !reflect.DeepEqual(existing.Mounts, container.Mounts) {
+		return true
+	}
+	if (existing.Config == nil) != (container.Config == nil) ||
+		existing.Config != nil && (existing.Config.Image != container.Config.Image ||
+			!maps.Equal(existing.Config.Labels, container.Config.Labels)) {
+		return true
+	}
+	if (existing.NetworkSettings == nil) != (container.NetworkSettings == nil) ||
+		existing.NetworkSettings != nil && !reflect.DeepEqual(existing.NetworkSettings.Ports, container.NetworkSettings.Ports) {
+		return true
+	}
+	return false
+}
+
+// commit saves and publishes a change, if any
+func (d *DnsStorage) commit(change *Change) {
+	if change == nil {
+		return
+	}
+	d.markDirty()
+	d.publish(*change)
+}
+
+// publish assigns the next revision to the change, appends it to the
+// changelog and sends it to all subscribers
+func (d *DnsStorage) publish(change Change) {
+	d.revision++
+	change.Revision = d.revision
+
+	d.changelog = append(d.changelog, change)
+	if len(d.changelog) > changelogSize {
+		d.changelog = d.changelog[len(d.changelog)-changelogSize:]
+	}
+
+	for subscription := range d.subscriptions {
+		select {
+		case subscription.Changes <- change:
+		default:
+			// never block the storage on a slow subscriber
+			d.handleUnsubscribe(subscription)
+		}
+	}
+}
+
+// drainPending handles all adds and removes already queued
+func (d *DnsStorage) drainPending() {
+	for {
+		select {
+		case op := <-d.hostChannel:
+			d.handleHostOperation(op)
+		default:
+			return
+		}
+	}
+}
+
+func (d *DnsStorage) handlePruneRestored(source string) {
+	for hostId, host := range d.GetHosts() {
+		if host.restored && host.Source == source {
+			d.handleRemoveHost(hostId)
+		}
 	}
 }
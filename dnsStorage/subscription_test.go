@@ -0,0 +1,133 @@
+package dnsStorage
+
+import (
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func nextChange(t *testing.T, s *Subscription) Change {
+	select {
+	case change, ok := <-s.Changes:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return change
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for change")
+	}
+	return Change{}
+}
+
+func TestSubscribeSince(t *testing.T) {
+	storage := NewDnsStorage(slog.Default())
+
+	first := storage.Subscribe()
+	if len(first.Snapshot) != 0 || first.Snapshot == nil {
+		t.Fatalf("expected empty snapshot, got %v", first.Snapshot)
+	}
+
+	storage.AddHost(Host{Id: "a", Name: "a.docker", Address: net.ParseIP("10.0.0.1")})
+	storage.AddHost(Host{Id: "b", Name: "b.docker", Address: net.ParseIP("10.0.0.2")})
+
+	add := nextChange(t, first)
+	if add.Type != ChangeAdd || add.HostId != "a" || add.Revision != first.Revision+1 {
+		t.Errorf("unexpected first change %+v", add)
+	}
+	nextChange(t, first)
+
+	storage.RemoveHost("a")
+	remove := nextChange(t, first)
+	if remove.Type != ChangeRemove || remove.Revision != first.Revision+3 {
+		t.Errorf("unexpected last change %+v", remove)
+	}
+
+	// resume after the first add
+	resumed := storage.SubscribeSince(add.Revision)
+	if resumed.Snapshot != nil || len(resumed.Backlog) != 2 || resumed.Backlog[1].Revision != remove.Revision {
+		t.Errorf("expected backlog of 2 changes, got snapshot=%v backlog=%+v", resumed.Snapshot, resumed.Backlog)
+	}
+
+	// revisions unknown to the changelog result in a snapshot
+	fresh := storage.SubscribeSince(remove.Revision + 100)
+	if _, ok := fresh.Snapshot["b"]; !ok || len(fresh.Snapshot) != 1 || fresh.Revision != remove.Revision {
+		t.Errorf("expected snapshot with b at %d, got %v at %d", remove.Revision, fresh.Snapshot, fresh.Revision)
+	}
+
+	storage.Unsubscribe(first)
+	if _, ok := <-first.Changes; ok {
+		t.Error("expected changes to be closed after unsubscribing")
+	}
+}
+
+func TestClose(t *testing.T) {
+	storage := NewDnsStorage(slog.Default())
+	subscription := storage.Subscribe()
+
+	storage.AddHost(Host{Id: "a", Name: "a.docker", Address: net.ParseIP("10.0.0.1")})
+	storage.Close()
+
+	// pending changes are applied and the subscription is closed afterwards
+	if change := nextChange(t, subscription); change.Type != ChangeAdd || change.Host.Id != "a" {
+		t.Errorf("unexpected change %+v", change)
+	}
+	if _, ok := <-subscription.Changes; ok {
+		t.Error("expected the subscription to be closed")
+	}
+	if storage.SubscriberCount() != 0 {
+		t.Errorf("expected no subscribers, got %d", storage.SubscriberCount())
+	}
+}
+
+func TestUpdateHost(t *testing.T) {
+	storage := NewDnsStorage(slog.Default())
+	defer storage.Close()
+	subscription := storage.Subscribe()
+
+	host := Host{Id: "web_bridge", Name: "web.docker", Address: net.ParseIP("172.17.0.2"), Source: SourceContainer}
+	storage.AddHost(host)
+	nextChange(t, subscription)
+
+	// adding the same host again changes nothing, a new address replaces it
+	storage.AddHost(host)
+	host.Address = net.ParseIP("172.17.0.9")
+	storage.AddHost(host)
+	if change := nextChange(t, subscription); change.Type != ChangeUpdate || !change.Host.Address.Equal(host.Address) {
+		t.Fatalf("expected an update to the new address, got %+v", change)
+	}
+	if addrs := storage.FindHostAddresses("web.docker."); len(addrs) != 1 || !addrs[0].Equal(host.Address) {
+		t.Errorf("expected the new address, got %v", addrs)
+	}
+
+	// the container publishing other ports updates the host as well
+	host.Container = &docker.Container{ID: "5f2bd1c9e5a0", NetworkSettings: &docker.NetworkSettings{}}
+	storage.AddHost(host)
+	nextChange(t, subscription)
+	host.Container = &docker.Container{ID: "5f2bd1c9e5a0", NetworkSettings: &docker.NetworkSettings{
+		Ports: map[docker.Port][]docker.PortBinding{"80/tcp": nil},
+	}}
+	storage.AddHost(host)
+	if change := nextChange(t, subscription); change.Type != ChangeUpdate || len(change.Host.Container.NetworkSettings.Ports) != 1 {
+		t.Fatalf("expected an update to the new ports, got %+v", change)
+	}
+}
+
+func TestOperationOrder(t *testing.T) {
+	storage := NewDnsStorage(slog.Default())
+	defer storage.Close()
+	subscription := storage.Subscribe()
+
+	for i := 0; i < 100; i++ {
+		storage.AddHost(Host{Id: "a", Name: "a.docker", Address: net.ParseIP("10.0.0.1"), Source: SourceApi})
+		storage.RemoveHost("a")
+	}
+	for i := 0; i < 200; i++ {
+		change := nextChange(t, subscription)
+		if expected := []string{ChangeAdd, ChangeRemove}[i%2]; change.Type != expected {
+			t.Fatalf("change %d: expected %s, got %s", i, expected, change.Type)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package health
+
+import (
+	"sort"
+	"sync"
+)
+
+// conditions which have to be met before dnsdock is ready
+const (
+	// ConditionDns is met while the dns listeners are up
+	ConditionDns = "dns"
+	// ConditionDocker is met while the docker event stream is connected
+	ConditionDocker = "docker"
+	// ConditionSync is met once the initial sync of the containers is complete
+	ConditionSync = "sync"
+)
+
+// Status tracks a set of conditions, it is ready if all of them are met
+type Status struct {
+	mutex      sync.RWMutex
+	conditions map[string]bool
+}
+
+// NewStatus creates a status where none of the given conditions is met yet
+func NewStatus(conditions ...string) *Status {
+	s := &Status{conditions: make(map[string]bool, len(conditions))}
+	for _, condition := range conditions {
+		s.conditions[condition] = false
+	}
+	return s
+}
+
+func (s *Status) Set(condition string, met bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.conditions[condition] = met
+}
+
+func (s *Status) Ready() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, met := range s.conditions {
+		if !met {
+			return false
+		}
+	}
+	return true
+}
+
+// Conditions returns a copy of all conditions and whether they are met
+func (s *Status) Conditions() map[string]bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	conditions := make(map[string]bool, len(s.conditions))
+	for condition, met := range s.conditions {
+		conditions[condition] = met
+	}
+	return conditions
+}
+
+// Pending returns the sorted names of the conditions not met
+func (s *Status) Pending() (pending []string) {
+	for condition, met := range s.Conditions() {
+		if !met {
+			pending = append(pending, condition)
+		}
+	}
+	sort.Strings(pending)
+	return
+}
@@ -0,0 +1,29 @@
+package health
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	status := NewStatus(ConditionDns, ConditionDocker, ConditionSync)
+	if status.Ready() {
+		t.Fatal("expected a new status not to be ready")
+	}
+
+	status.Set(ConditionDocker, true)
+	if pending := status.Pending(); !reflect.DeepEqual(pending, []string{ConditionDns, ConditionSync}) {
+		t.Errorf("unexpected pending conditions %v", pending)
+	}
+
+	status.Set(ConditionDns, true)
+	status.Set(ConditionSync, true)
+	if !status.Ready() {
+		t.Errorf("expected status to be ready, pending: %v", status.Pending())
+	}
+
+	status.Set(ConditionDocker, false)
+	if status.Ready() {
+		t.Error("expected status not to be ready after losing a condition")
+	}
+}
@@ -0,0 +1,81 @@
+package hostIntegration
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolved(t *testing.T) {
+	var commands []string
+	b := &resolved{link: "docker0", run: func(name string, args ...string) error {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return nil
+	}}
+
+	settings := Settings{Address: "172.17.0.2", Domain: "docker", ReverseZones: []string{"17.172.in-addr.arpa"}}
+	if err := b.Write(settings); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Remove(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"resolvectl dns docker0 172.17.0.2",
+		"resolvectl domain docker0 ~docker ~17.172.in-addr.arpa",
+		"resolvectl revert docker0",
+	}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("expected %q, got %q", expected, commands)
+	}
+}
+
+func TestResolverDir(t *testing.T) {
+	dir := t.TempDir()
+
+	// a file of the user and a zone written by a previous run
+	if err := os.WriteFile(filepath.Join(dir, "example.com"), []byte("nameserver 10.0.0.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "19.172.in-addr.arpa"), []byte(resolverDirMarker+"\nnameserver 172.17.0.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := func() (names []string) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return
+	}
+
+	b := &resolverDir{dir: dir}
+	settings := Settings{Address: "172.17.0.2", Domain: "docker", ReverseZones: []string{"17.172.in-addr.arpa", "18.172.in-addr.arpa"}}
+	if err := b.Write(settings); err != nil {
+		t.Fatal(err)
+	}
+	if names, expected := files(), []string{"17.172.in-addr.arpa", "18.172.in-addr.arpa", "docker", "example.com"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+
+	settings.ReverseZones = settings.ReverseZones[:1]
+	if err := b.Write(settings); err != nil {
+		t.Fatal(err)
+	}
+	if names, expected := files(), []string{"17.172.in-addr.arpa", "docker", "example.com"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+
+	if err := b.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if names, expected := files(), []string{"example.com"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
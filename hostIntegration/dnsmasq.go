@@ -0,0 +1,31 @@
+package hostIntegration
+
+import "fmt"
+
+// dnsmasq writes a drop-in forwarding the dnsdock zones, e.g. for
+// NetworkManager's dnsmasq instance.
+type dnsmasq struct {
+	path string
+}
+
+func (b *dnsmasq) Name() string {
+	return "dnsmasq"
+}
+
+func (b *dnsmasq) Write(settings Settings) error {
+	conf := make([]string, 0, len(settings.ReverseZones)+1)
+
+	// add forward dns for *.docker
+	conf = append(conf, fmt.Sprintf("server=/%s/%s", settings.Domain, settings.Address))
+
+	// add reverse dns for the docker networks
+	for _, zone := range settings.ReverseZones {
+		conf = append(conf, fmt.Sprintf("server=/%s/%s", zone, settings.Address))
+	}
+
+	return writeLines(b.path, conf)
+}
+
+func (b *dnsmasq) Remove() error {
+	return removeFile(b.path)
+}
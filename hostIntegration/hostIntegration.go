@@ -0,0 +1,87 @@
+package hostIntegration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Settings describes what the host's resolver should be pointed at.
+type Settings struct {
+	// Address is the address the dnsdock resolver is reachable at from the host
+	Address string
+	// Domain is the forward zone served by dnsdock, e.g. "docker"
+	Domain string
+	// ReverseZones are the in-addr.arpa and ip6.arpa zones covering the docker networks
+	ReverseZones []string
+}
+
+// Backend integrates dnsdock into one kind of host resolver configuration.
+type Backend interface {
+	Name() string
+	Write(settings Settings) error
+	Remove() error
+}
+
+// NewBackends parses a comma separated list of backends. Every entry is a
+// backend name optionally followed by a colon and the path the backend writes
+// to, or the network link for resolved, e.g.
+// "dnsmasq:/etc/NetworkManager/dnsmasq.d/dnsdock,resolved:docker0".
+func NewBackends(list string) (backends []Backend, err error) {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || entry == "none" {
+			continue
+		}
+
+		name, path := entry, ""
+		if i := strings.Index(entry, ":"); i >= 0 {
+			name, path = entry[:i], entry[i+1:]
+		}
+
+		backend, err := NewBackend(name, path)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+	return
+}
+
+// NewBackend creates the backend of the given name. An empty path selects the
+// backend's default location.
+func NewBackend(name, path string) (Backend, error) {
+	switch name {
+	case "dnsmasq":
+		return &dnsmasq{path: pathOrDefault(path, "/etc/dnsmasq.d/dnsdock")}, nil
+	case "resolved":
+		return &resolved{link: pathOrDefault(path, "docker0"), run: runCommand}, nil
+	case "resolver":
+		return &resolverDir{dir: pathOrDefault(path, "/etc/resolver")}, nil
+	case "resolvconf":
+		return &resolvConf{path: pathOrDefault(path, "/etc/dnsdock/resolv.conf")}, nil
+	}
+	return nil, fmt.Errorf("unknown dns integration backend: %s", name)
+}
+
+func pathOrDefault(path, def string) string {
+	if path == "" {
+		return def
+	}
+	return path
+}
+
+func writeLines(path string, lines []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package hostIntegration
+
+// resolvConf writes a resolv.conf snippet to be put in front of the host's
+// nameservers, e.g. as /etc/resolvconf/resolv.conf.d/head. It has no per zone
+// routing, hence all queries go to dnsdock first, which refuses names outside
+// of its zone so they are passed on to the next nameserver. The search list
+// of the host is left as is.
+type resolvConf struct {
+	path string
+}
+
+func (b *resolvConf) Name() string {
+	return "resolvconf"
+}
+
+func (b *resolvConf) Write(settings Settings) error {
+	return writeLines(b.path, []string{
+		"# generated by dnsdock",
+		"nameserver " + settings.Address,
+	})
+}
+
+func (b *resolvConf) Remove() error {
+	return removeFile(b.path)
+}
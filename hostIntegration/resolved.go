@@ -0,0 +1,46 @@
+package hostIntegration
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// resolved configures systemd-resolved to route the dnsdock zones to dnsdock
+// on the docker bridge link only, using resolvectl. The settings are reverted
+// on removal and are lost when systemd-resolved restarts. It needs resolvectl
+// and access to the host's system bus, e.g. when running dnsdock on the host.
+type resolved struct {
+	link string
+	// run executes a command, replaced in tests
+	run func(name string, args ...string) error
+}
+
+func runCommand(name string, args ...string) error {
+	if output, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (b *resolved) Name() string {
+	return "resolved"
+}
+
+func (b *resolved) Write(settings Settings) error {
+	// the ~ prefix makes the domains routing-only instead of search domains
+	domains := make([]string, 0, len(settings.ReverseZones)+1)
+	domains = append(domains, "~"+settings.Domain)
+	for _, zone := range settings.ReverseZones {
+		domains = append(domains, "~"+zone)
+	}
+
+	if err := b.run("resolvectl", "dns", b.link, settings.Address); err != nil {
+		return err
+	}
+	return b.run("resolvectl", append([]string{"domain", b.link}, domains...)...)
+}
+
+func (b *resolved) Remove() error {
+	return b.run("resolvectl", "revert", b.link)
+}
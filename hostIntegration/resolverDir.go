@@ -0,0 +1,69 @@
+package hostIntegration
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+// first line of the files written, files without it are left alone
+const resolverDirMarker = "# managed by dnsdock"
+
+// resolverDir writes one file per zone into a macOS style /etc/resolver
+// directory.
+type resolverDir struct {
+	dir string
+}
+
+func (b *resolverDir) Name() string {
+	return "resolver"
+}
+
+func (b *resolverDir) Write(settings Settings) error {
+	zones := append([]string{settings.Domain}, settings.ReverseZones...)
+
+	wanted := make(map[string]bool, len(zones))
+	for _, zone := range zones {
+		path := filepath.Join(b.dir, zone)
+		if err := writeLines(path, []string{resolverDirMarker, "nameserver " + settings.Address}); err != nil {
+			return err
+		}
+		wanted[path] = true
+	}
+
+	// remove files of zones which are gone, also of previous runs
+	return b.removeManaged(wanted)
+}
+
+func (b *resolverDir) Remove() error {
+	return b.removeManaged(nil)
+}
+
+// removeManaged removes the files written by dnsdock except the wanted ones
+func (b *resolverDir) removeManaged(wanted map[string]bool) error {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(b.dir, entry.Name())
+		if entry.IsDir() || wanted[path] {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(data, []byte(resolverDirMarker+"\n")) {
+			continue
+		}
+		if err := removeFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package hostIntegration
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ReverseZones returns the minimal set of in-addr.arpa and ip6.arpa zones
+// covering the given subnets. Zones are delegated on octet (IPv4) or nibble
+// (IPv6) boundaries, hence subnets with other prefix lengths are expanded,
+// e.g. 172.16.0.0/12 results in 16.172.in-addr.arpa up to 31.172.in-addr.arpa.
+// Zones contained in another zone of the set are omitted.
+func ReverseZones(subnets []*net.IPNet) []string {
+	zones := make(map[string]bool)
+
+	for _, subnet := range subnets {
+		for _, zone := range subnetZones(subnet) {
+			zones[zone] = true
+		}
+	}
+
+	ret := make([]string, 0, len(zones))
+	for zone := range zones {
+		if !coveredByParent(zone, zones) {
+			ret = append(ret, zone)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func subnetZones(subnet *net.IPNet) (zones []string) {
+	prefix, bits := subnet.Mask.Size()
+
+	// ipv4 zones are delegated per octet, but never go beyond a /24 zone;
+	// ipv6 zones are delegated per nibble
+	step, maxBits := 8, 24
+	ip := subnet.IP.To4()
+	if ip == nil || bits == 128 {
+		step, maxBits = 4, 128
+		ip = subnet.IP.To16()
+	}
+	if ip == nil || prefix == 0 {
+		return nil
+	}
+
+	zoneBits := (prefix + step - 1) / step * step
+	if zoneBits > maxBits {
+		zoneBits = maxBits
+	}
+	if prefix > zoneBits {
+		prefix = zoneBits
+	}
+
+	base := ip.Mask(net.CIDRMask(prefix, len(ip)*8))
+	for i := 0; i < 1<<uint(zoneBits-prefix); i++ {
+		zone := make(net.IP, len(base))
+		copy(zone, base)
+		addToPrefix(zone, zoneBits, i)
+		zones = append(zones, reverseZone(zone, zoneBits, step))
+	}
+	return
+}
+
+// addToPrefix adds n to the network part of a prefix of the given length
+func addToPrefix(ip net.IP, prefix, n int) {
+	shift := uint((8 - prefix%8) % 8)
+	carry := n << shift
+	for i := (prefix+7)/8 - 1; i >= 0 && carry > 0; i-- {
+		sum := int(ip[i]) + carry
+		ip[i] = byte(sum)
+		carry = sum >> 8
+	}
+}
+
+func reverseZone(ip net.IP, zoneBits, step int) string {
+	if step == 8 {
+		labels := []string{"in-addr.arpa"}
+		for i := 0; i < zoneBits/8; i++ {
+			labels = append([]string{fmt.Sprintf("%d", ip[i])}, labels...)
+		}
+		return strings.Join(labels, ".")
+	}
+
+	labels := []string{"ip6.arpa"}
+	for i := 0; i < zoneBits/4; i++ {
+		nibble := ip[i/2] >> 4
+		if i%2 == 1 {
+			nibble = ip[i/2] & 0x0f
+		}
+		labels = append([]string{fmt.Sprintf("%x", nibble)}, labels...)
+	}
+	return strings.Join(labels, ".")
+}
+
+func coveredByParent(zone string, zones map[string]bool) bool {
+	for i := strings.Index(zone, "."); i >= 0; i = strings.Index(zone, ".") {
+		zone = zone[i+1:]
+		if zones[zone] {
+			return true
+		}
+	}
+	return false
+}
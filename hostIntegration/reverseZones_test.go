@@ -0,0 +1,49 @@
+package hostIntegration
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestReverseZones(t *testing.T) {
+	tests := []struct {
+		subnets []string
+		zones   []string
+	}{
+		{[]string{"10.0.0.0/8"}, []string{"10.in-addr.arpa"}},
+		{[]string{"192.168.5.0/24"}, []string{"5.168.192.in-addr.arpa"}},
+		{[]string{"192.168.5.128/25"}, []string{"5.168.192.in-addr.arpa"}},
+		{[]string{"172.16.0.0/14"}, []string{
+			"16.172.in-addr.arpa",
+			"17.172.in-addr.arpa",
+			"18.172.in-addr.arpa",
+			"19.172.in-addr.arpa",
+		}},
+		{[]string{"172.18.0.0/16", "172.18.0.0/16"}, []string{"18.172.in-addr.arpa"}},
+		{[]string{"10.0.0.0/8", "10.1.0.0/16", "192.168.0.0/24"}, []string{
+			"0.168.192.in-addr.arpa",
+			"10.in-addr.arpa",
+		}},
+		{[]string{"fd00:1:2:3::/64"}, []string{"3.0.0.0.2.0.0.0.1.0.0.0.0.0.d.f.ip6.arpa"}},
+		{[]string{"fd00:abcd::/31"}, []string{
+			"c.c.b.a.0.0.d.f.ip6.arpa",
+			"d.c.b.a.0.0.d.f.ip6.arpa",
+		}},
+	}
+
+	for _, test := range tests {
+		var subnets []*net.IPNet
+		for _, s := range test.subnets {
+			_, subnet, err := net.ParseCIDR(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			subnets = append(subnets, subnet)
+		}
+
+		if zones := ReverseZones(subnets); !reflect.DeepEqual(zones, test.zones) {
+			t.Errorf("ReverseZones(%v) = %v, expected %v", test.subnets, zones, test.zones)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package hostsFile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/koestler/dnsdock/dnsStorage"
+)
+
+const (
+	beginMarker = "# BEGIN dnsdock"
+	endMarker   = "# END dnsdock"
+
+	// wait this long after a change for further changes before rewriting the file
+	debounce = 200 * time.Millisecond
+)
+
+// Writer maintains a hosts-format file rendered from the hosts of a DnsStorage.
+type Writer struct {
+	Path string
+	// Managed restricts the writer to a marked block within the file, keeping
+	// the rest of it (e.g. the system's /etc/hosts entries) untouched
+	Managed bool
+	Storage *dnsStorage.DnsStorage
+	Logger  *slog.Logger
+}
+
+// Run writes the file and rewrites it on every change of the storage until
+// ctx is done.
+func (w *Writer) Run(ctx context.Context) error {
+	subscription := w.Storage.Subscribe()
+	defer func() {
+		w.Storage.Unsubscribe(subscription)
+	}()
+
+	if err := w.Write(); err != nil {
+		w.Logger.Error("could not write hosts file", "path", w.Path, "err", err)
+	}
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case _, ok := <-subscription.Changes:
+			if !ok {
+				// fell behind, the file is rewritten from the current state anyway
+				subscription = w.Storage.Subscribe()
+			}
+		case <-pending:
+			pending = nil
+			if err := w.Write(); err != nil {
+				w.Logger.Error("could not write hosts file", "path", w.Path, "err", err)
+			}
+			continue
+		case <-ctx.Done():
+			return nil
+		}
+
+		if pending == nil {
+			pending = time.After(debounce)
+		}
+	}
+}
+
+// Write renders the current hosts into the file.
+func (w *Writer) Write() error {
+	content := render(w.Storage.GetHosts())
+
+	if w.Managed {
+		existing, err := os.ReadFile(w.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		content = replaceBlock(existing, content)
+	}
+
+	return writeAtomic(w.Path, content)
+}
+
+// Remove empties the file or removes the managed block within it. The file
+// itself is kept since it is likely bind mounted somewhere.
+func (w *Writer) Remove() error {
+	if !w.Managed {
+		return writeAtomic(w.Path, nil)
+	}
+
+	existing, err := os.ReadFile(w.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return writeAtomic(w.Path, replaceBlock(existing, nil))
+}
+
+func render(hosts dnsStorage.Hosts) []byte {
+	ids := make([]string, 0, len(hosts))
+	for id, host := range hosts {
+		if host.Address == nil || host.Address.IsUnspecified() {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var b bytes.Buffer
+	for _, id := range ids {
+		host := hosts[id]
+		names := append([]string{host.Name}, host.Aliases...)
+		fmt.Fprintf(&b, "%s\t%s\n", host.Address, strings.Join(names, " "))
+	}
+	return b.Bytes()
+}
+
+// replaceBlock replaces the dnsdock block within existing by content. The
+// block is appended if there is none yet and removed if content is nil.
+func replaceBlock(existing, content []byte) []byte {
+	var b bytes.Buffer
+	inBlock := false
+
+	for _, line := range strings.SplitAfter(string(existing), "\n") {
+		switch strings.TrimSpace(line) {
+		case beginMarker:
+			inBlock = true
+		case endMarker:
+			inBlock = false
+		default:
+			if !inBlock && line != "" {
+				b.WriteString(line)
+			}
+		}
+	}
+
+	if content == nil {
+		return b.Bytes()
+	}
+
+	if b.Len() > 0 && !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+		b.WriteString("\n")
+	}
+	b.WriteString(beginMarker + "\n")
+	b.Write(content)
+	b.WriteString(endMarker + "\n")
+	return b.Bytes()
+}
+
+// writeAtomic replaces the file by renaming a temporary file over it. Bind
+// mounted files (like /etc/hosts inside a container) cannot be replaced,
+// those are rewritten in place instead.
+func writeAtomic(path string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return os.WriteFile(path, content, mode)
+	}
+	return nil
+}
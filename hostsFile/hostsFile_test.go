@@ -0,0 +1,34 @@
+package hostsFile
+
+import "testing"
+
+func TestReplaceBlock(t *testing.T) {
+	tests := []struct {
+		existing string
+		content  []byte
+		expected string
+	}{
+		{"", []byte("1.2.3.4\ta.docker\n"), "# BEGIN dnsdock\n1.2.3.4\ta.docker\n# END dnsdock\n"},
+		{
+			"127.0.0.1\tlocalhost",
+			[]byte("1.2.3.4\ta.docker\n"),
+			"127.0.0.1\tlocalhost\n# BEGIN dnsdock\n1.2.3.4\ta.docker\n# END dnsdock\n",
+		},
+		{
+			"127.0.0.1\tlocalhost\n# BEGIN dnsdock\n1.2.3.4\told.docker\n# END dnsdock\n::1\tlocalhost\n",
+			[]byte("1.2.3.4\ta.docker\n"),
+			"127.0.0.1\tlocalhost\n::1\tlocalhost\n# BEGIN dnsdock\n1.2.3.4\ta.docker\n# END dnsdock\n",
+		},
+		{
+			"127.0.0.1\tlocalhost\n# BEGIN dnsdock\n1.2.3.4\told.docker\n# END dnsdock\n",
+			nil,
+			"127.0.0.1\tlocalhost\n",
+		},
+	}
+
+	for _, test := range tests {
+		if got := string(replaceBlock([]byte(test.existing), test.content)); got != test.expected {
+			t.Errorf("replaceBlock(%q, %q) = %q, expected %q", test.existing, test.content, got, test.expected)
+		}
+	}
+}
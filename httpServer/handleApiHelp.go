@@ -1,11 +1,91 @@
 package httpServer
 
 import (
-	"net/http"
+	_ "embed"
+	"encoding/json"
 	"errors"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
 )
 
+//go:embed openapi.json
+var openApiSpec []byte
+
+var apiIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><title>dnsdock api</title></head>
+<body>
+<h1>dnsdock api</h1>
+<p>Machine readable description: <a href="{{.Base}}/openapi.json">{{.Base}}/openapi.json</a></p>
+<table>
+{{range .Operations}}<tr><td>{{.Method}}</td><td>{{$.Base}}{{.Path}}</td><td>{{.Summary}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type apiOperation struct {
+	Method  string
+	Path    string
+	Summary string
+}
+
 func HandleApiNotFound(env *Environment, w http.ResponseWriter, r *http.Request) Error {
 	err := errors.New("api method not found")
 	return StatusError{404, err}
 }
+
+func HandleOpenApi(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if _, err := w.Write(openApiSpec); err != nil {
+		return StatusError{500, err}
+	}
+	return nil
+}
+
+// HandleApiIndex renders a human readable list of the operations described in the OpenAPI document
+func HandleApiIndex(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	var spec struct {
+		Servers []struct {
+			Url string
+		}
+		Paths map[string]map[string]struct {
+			Summary string
+		}
+	}
+	if err := json.Unmarshal(openApiSpec, &spec); err != nil {
+		return StatusError{500, err}
+	}
+
+	var operations []apiOperation
+	for path, methods := range spec.Paths {
+		for method, operation := range methods {
+			operations = append(operations, apiOperation{strings.ToUpper(method), path, operation.Summary})
+		}
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		if operations[i].Path == operations[j].Path {
+			return operations[i].Method < operations[j].Method
+		}
+		return operations[i].Path < operations[j].Path
+	})
+
+	base := ""
+	if len(spec.Servers) > 0 {
+		base = spec.Servers[0].Url
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	err := apiIndexTemplate.Execute(w, struct {
+		Base       string
+		Operations []apiOperation
+	}{base, operations})
+	if err != nil {
+		return StatusError{500, err}
+	}
+	return nil
+}
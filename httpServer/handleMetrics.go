@@ -0,0 +1,12 @@
+package httpServer
+
+import (
+	"net/http"
+
+	"github.com/koestler/dnsdock/metrics"
+)
+
+func HandleMetrics(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	metrics.Handler().ServeHTTP(w, r)
+	return nil
+}
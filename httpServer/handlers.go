@@ -1,13 +1,37 @@
 package httpServer
 
 import (
+	"encoding/json"
 	"github.com/koestler/dnsdock/dnsStorage"
-	"log"
+	"github.com/koestler/dnsdock/health"
+	"github.com/koestler/dnsdock/resolver"
+	"log/slog"
 	"net/http"
+	"sync"
 )
 
 type Environment struct {
-	Storage *dnsStorage.DnsStorage
+	Storage  *dnsStorage.DnsStorage
+	Resolver *resolver.DnsResolver
+	Logger   *slog.Logger
+	Health   *health.Status
+	// Reload reloads the configuration, nil if not supported
+	Reload func() (ReloadResponse, error)
+
+	// closed when the server shuts down
+	shutdown chan struct{}
+	// running websocket clients
+	streams sync.WaitGroup
+}
+
+// isShuttingDown reports whether streams should no longer be started
+func (env *Environment) isShuttingDown() bool {
+	select {
+	case <-env.shutdown:
+		return true
+	default:
+		return false
+	}
 }
 
 // Error represents a handler error. It provides methods for a HTTP status
@@ -42,21 +66,55 @@ func (handler Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	err := handler.Handle(handler.Env, w, r)
 
 	if err != nil {
-		log.Printf("ServeHTTP err=%v", err)
-
 		switch e := err.(type) {
 		case Error:
 			// We can retrieve the status here and write out a specific
 			// HTTP status code.
-			log.Printf("HTTP %d - %s", e.Status(), e)
-			http.Error(w, http.StatusText(e.Status()), e.Status())
+			handler.Env.Logger.Debug("request failed", "path", r.URL.Path, "status", e.Status(), "err", e)
+			writeJsonError(w, e.Status(), e.Error())
 			return
 		default:
 			// Any error types we don't specifically look out for default
 			// to serving a HTTP 500
-			http.Error(w, http.StatusText(http.StatusInternalServerError),
-				http.StatusInternalServerError)
+			handler.Env.Logger.Error("request failed", "path", r.URL.Path, "err", err)
+			writeJsonError(w, http.StatusInternalServerError,
+				http.StatusText(http.StatusInternalServerError))
 			return
 		}
 	}
 }
+
+// ErrorResponse is the body of all error responses
+type ErrorResponse struct {
+	Code    int
+	Message string
+}
+
+func writeJsonError(w http.ResponseWriter, code int, message string) {
+	b, _ := json.Marshal(ErrorResponse{Code: code, Message: message})
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// writeJsonResponse writes v as indented json using the given status code
+func writeJsonResponse(w http.ResponseWriter, status int, v interface{}) Error {
+	b, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return StatusError{500, err}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(status)
+
+	_, err = w.Write(b)
+	if err != nil {
+		return StatusError{500, err}
+	}
+	return nil
+}
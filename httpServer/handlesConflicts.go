@@ -0,0 +1,10 @@
+package httpServer
+
+import (
+	"net/http"
+)
+
+// HandleGetConflicts returns the names claimed by hosts of different owners
+func HandleGetConflicts(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	return writeJsonResponse(w, http.StatusOK, env.Storage.Conflicts())
+}
@@ -0,0 +1,68 @@
+package httpServer
+
+import (
+	_ "embed"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+//go:embed dashboard.html
+var dashboardHtml []byte
+
+type ResolveResponse struct {
+	Name    string
+	Type    string
+	Rcode   string
+	Answers []string
+}
+
+func HandleDashboard(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	if _, err := w.Write(dashboardHtml); err != nil {
+		return StatusError{500, err}
+	}
+	return nil
+}
+
+// HandleResolve queries the resolver, e.g. /api/v1/Resolve?name=web.myproject.docker&type=A
+func HandleResolve(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		return StatusError{400, errors.New("missing name")}
+	}
+
+	qtypeName := strings.ToUpper(r.URL.Query().Get("type"))
+	if qtypeName == "" {
+		qtypeName = "A"
+	}
+	qtype, ok := dns.StringToType[qtypeName]
+	if !ok {
+		return StatusError{400, errors.New("unknown type: " + qtypeName)}
+	}
+
+	// allow to pass addresses for PTR lookups
+	if qtype == dns.TypePTR {
+		if reverse, err := dns.ReverseAddr(name); err == nil {
+			name = reverse
+		}
+	}
+
+	resp, err := env.Resolver.Resolve(name, qtype)
+	if err != nil {
+		return StatusError{500, err}
+	}
+
+	response := ResolveResponse{
+		Name:    dns.Fqdn(name),
+		Type:    qtypeName,
+		Rcode:   dns.RcodeToString[resp.Rcode],
+		Answers: []string{},
+	}
+	for _, rr := range resp.Answer {
+		response.Answers = append(response.Answers, rr.String())
+	}
+	return writeJsonResponse(w, http.StatusOK, response)
+}
@@ -0,0 +1,125 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/koestler/dnsdock/dnsStorage"
+)
+
+// keep idle connections alive through proxies
+const eventsKeepAlive = 30 * time.Second
+
+// HandleEvents streams the hosts as Server-Sent Events: an initial snapshot
+// followed by add, update and remove events. Clients reconnecting with a
+// Last-Event-ID header only receive the events they missed.
+func HandleEvents(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return StatusError{500, errors.New("streaming not supported")}
+	}
+
+	// event ids are storage revisions
+	lastId, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+
+	subscription := env.Storage.SubscribeSince(lastId)
+	defer env.Storage.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+
+	// a resumed client may have any of the hosts
+	stream := &eventStream{w: w, sent: make(map[string]bool), resumed: subscription.Snapshot == nil}
+	if subscription.Snapshot != nil {
+		snapshot := filterHosts(subscription.Snapshot, hostFilter{})
+		for hostId := range snapshot {
+			stream.sent[hostId] = true
+		}
+		if err := writeEvent(w, subscription.Revision, "snapshot", snapshot); err != nil {
+			return nil
+		}
+	}
+	for _, change := range subscription.Backlog {
+		if err := stream.writeChange(change); err != nil {
+			return nil
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case change, ok := <-subscription.Changes:
+			if !ok {
+				// too slow, the client reconnects and resumes
+				return nil
+			}
+			if err := stream.writeChange(change); err != nil {
+				return nil
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+		case <-r.Context().Done():
+			return nil
+		case <-env.shutdown:
+			return nil
+		}
+		flusher.Flush()
+	}
+}
+
+// eventStream writes the changes of the hosts visible to one client
+type eventStream struct {
+	w       http.ResponseWriter
+	resumed bool
+	// whether a host was sent to the client, to send a remove when it is no
+	// longer visible and an add when it becomes visible. Hosts not seen yet
+	// were sent unless the stream started with a snapshot.
+	sent map[string]bool
+}
+
+func (s *eventStream) writeChange(change dnsStorage.Change) error {
+	sent, seen := s.sent[change.HostId]
+	if !seen {
+		sent = s.resumed
+	}
+
+	if change.Type != dnsStorage.ChangeRemove && isVisible(change.Host) {
+		s.sent[change.HostId] = true
+		eventType := change.Type
+		if !sent {
+			eventType = dnsStorage.ChangeAdd
+		}
+		return writeEvent(s.w, change.Revision, eventType, AddMessage{Type: eventType, HostId: change.HostId, Host: convertHost(change.Host)})
+	}
+
+	// removed or no longer visible
+	if change.Type == dnsStorage.ChangeRemove {
+		delete(s.sent, change.HostId)
+	} else {
+		s.sent[change.HostId] = false
+	}
+	if !sent {
+		return nil
+	}
+	return writeEvent(s.w, change.Revision, dnsStorage.ChangeRemove, RemoveMessage{Type: dnsStorage.ChangeRemove, HostId: change.HostId})
+}
+
+func writeEvent(w http.ResponseWriter, id uint64, eventType string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, eventType, b)
+	return err
+}
@@ -0,0 +1,152 @@
+package httpServer
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koestler/dnsdock/dnsStorage"
+)
+
+type event struct {
+	id        uint64
+	eventType string
+	err       error
+}
+
+// nextEvent reads the next event, failing the test on errors
+func nextEvent(t *testing.T, reader *bufio.Reader) event {
+	e := readEvent(reader)
+	if e.err != nil {
+		t.Fatal(e.err)
+	}
+	return e
+}
+
+// readEvent returns the id and type of the next event, skipping comments
+func readEvent(reader *bufio.Reader) (e event) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			e.err = err
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" && e.eventType != "":
+			return
+		case strings.HasPrefix(line, "id: "):
+			if e.id, e.err = strconv.ParseUint(line[4:], 10, 64); e.err != nil {
+				return
+			}
+		case strings.HasPrefix(line, "event: "):
+			e.eventType = line[7:]
+		}
+	}
+}
+
+func openEvents(t *testing.T, url, lastId string) *bufio.Reader {
+	req, err := http.NewRequest("GET", url+"/api/v1/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastId != "" {
+		req.Header.Set("Last-Event-ID", lastId)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %s", ct)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+func TestEvents(t *testing.T) {
+	env := newTestEnvironment(t)
+	server := httptest.NewServer(newRouter(nil, env))
+	t.Cleanup(server.Close)
+
+	events := openEvents(t, server.URL, "")
+	if e := nextEvent(t, events); e.eventType != "snapshot" {
+		t.Fatalf("expected snapshot, got %s", e.eventType)
+	}
+
+	env.Storage.AddHost(dnsStorage.Host{Id: "static_2", Name: "b.docker", Address: net.ParseIP("10.0.0.2"), Source: dnsStorage.SourceStatic})
+	add := nextEvent(t, events)
+	if add.eventType != "add" {
+		t.Fatalf("expected add, got %s", add.eventType)
+	}
+
+	env.Storage.RemoveHost("static_2")
+	remove := nextEvent(t, events)
+	if remove.eventType != "remove" || remove.id <= add.id {
+		t.Fatalf("expected remove with id > %d, got %s %d", add.id, remove.eventType, remove.id)
+	}
+
+	// resume after the add event
+	resumed := openEvents(t, server.URL, strconv.FormatUint(add.id, 10))
+	received := make(chan event, 1)
+	go func() {
+		received <- readEvent(resumed)
+	}()
+	select {
+	case e := <-received:
+		if e.err != nil {
+			t.Fatal(e.err)
+		}
+		if e.id != remove.id || e.eventType != "remove" {
+			t.Errorf("expected to resume with remove %d, got %s %d", remove.id, e.eventType, e.id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for resumed event")
+	}
+}
+
+func TestEventsVisibility(t *testing.T) {
+	env := newTestEnvironment(t)
+	server := httptest.NewServer(newRouter(nil, env))
+	t.Cleanup(server.Close)
+
+	events := openEvents(t, server.URL, "")
+	if e := nextEvent(t, events); e.eventType != "snapshot" {
+		t.Fatalf("expected snapshot, got %s", e.eventType)
+	}
+
+	host := dnsStorage.Host{Id: "web_bridge", Name: "web.docker", Address: net.ParseIP("172.17.0.2"), Source: dnsStorage.SourceContainer}
+	hidden := host
+	hidden.Address = net.ParseIP("::1")
+
+	// a host leaving the visible ones is removed and added when it is back
+	for _, test := range []struct {
+		host      dnsStorage.Host
+		eventType string
+	}{
+		{host, "add"},
+		{hidden, "remove"},
+		{host, "add"},
+	} {
+		env.Storage.AddHost(test.host)
+		if e := nextEvent(t, events); e.eventType != test.eventType {
+			t.Fatalf("%s: expected %s, got %s", test.host.Address, test.eventType, e.eventType)
+		}
+	}
+
+	// hidden hosts are not sent at all
+	env.Storage.AddHost(hidden)
+	env.Storage.RemoveHost("web_bridge")
+	env.Storage.AddHost(dnsStorage.Host{Id: "static_2", Name: "b.docker", Address: net.ParseIP("10.0.0.2"), Source: dnsStorage.SourceStatic})
+	for _, eventType := range []string{"remove", "add"} {
+		if e := nextEvent(t, events); e.eventType != eventType {
+			t.Fatalf("expected %s, got %s", eventType, e.eventType)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package httpServer
+
+import (
+	"net/http"
+)
+
+type HealthResponse struct {
+	Status string
+}
+
+type ReadinessResponse struct {
+	Ready      bool
+	Conditions map[string]bool
+}
+
+// HandleHealthz answers as long as the process is alive
+func HandleHealthz(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	return writeJsonResponse(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// HandleReadyz answers with 503 until the dns listeners are up, the docker
+// event stream is connected and the initial sync is complete
+func HandleReadyz(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	response := ReadinessResponse{
+		Ready:      env.Health.Ready(),
+		Conditions: env.Health.Conditions(),
+	}
+
+	status := http.StatusOK
+	if !response.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	return writeJsonResponse(w, status, response)
+}
@@ -0,0 +1,52 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/koestler/dnsdock/health"
+	"github.com/miekg/dns"
+)
+
+func TestReadiness(t *testing.T) {
+	env := newTestEnvironment(t)
+	router := newRouter(nil, env)
+
+	selfCheck := func() *dns.Msg {
+		query := new(dns.Msg)
+		query.SetQuestion("_dnsdock.docker.", dns.TypeTXT)
+		w := &testResponseWriter{}
+		env.Resolver.ServeDNS(w, query)
+		return w.response
+	}
+
+	readyz := func() (int, ReadinessResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		var response ReadinessResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, response
+	}
+
+	code, response := readyz()
+	if code != http.StatusServiceUnavailable || response.Ready || response.Conditions[health.ConditionDns] {
+		t.Errorf("expected not to be ready, got %d %+v", code, response)
+	}
+	if resp := selfCheck(); resp.Rcode != dns.RcodeServerFailure {
+		t.Errorf("expected self-check to fail, got %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	env.Health.Set(health.ConditionDns, true)
+
+	code, response = readyz()
+	if code != http.StatusOK || !response.Ready {
+		t.Errorf("expected to be ready, got %d %+v", code, response)
+	}
+	if resp := selfCheck(); resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+		t.Errorf("expected self-check to succeed, got %v", resp)
+	}
+}
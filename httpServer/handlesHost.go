@@ -1,124 +1,84 @@
 package httpServer
 
 import (
-	"encoding/json"
-	"github.com/gorilla/websocket"
-	"log"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/koestler/dnsdock/dnsStorage"
+	"github.com/miekg/dns"
 	"net/http"
+	"strings"
 )
 
 func HandleGetHosts(env *Environment, w http.ResponseWriter, r *http.Request) Error {
-	response := getAllHosts(env)
-
-	w.Header().Set("Content-Model", "application/json; charset=UTF-8")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
-	b, err := json.MarshalIndent(response, "", "    ")
-	if err != nil {
-		return StatusError{500, err}
-	}
+	response := filterHosts(env.Storage.GetHosts(), newHostFilter(r.URL.Query()))
+	return writeJsonResponse(w, http.StatusOK, response)
+}
 
-	_, err = w.Write(b)
-	if err != nil {
-		return StatusError{500, err}
+func HandleGetHost(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	host, ok := env.Storage.GetHost(mux.Vars(r)["Id"])
+	if !ok || !isVisible(host) {
+		return StatusError{404, errors.New("host not found")}
 	}
-	return nil
+	return writeJsonResponse(w, http.StatusOK, convertHost(host))
 }
 
-func HandleWsHosts(env *Environment, w http.ResponseWriter, r *http.Request) Error {
-	// upgrade to websocket
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("upgraded failed: %v", err)
-		return nil
+// HandleGetName lists the hosts answering for a name, either by their name or an alias
+func HandleGetName(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	hosts := make(map[string]dnsStorage.Host)
+	for _, host := range env.Storage.FindHosts(dns.Fqdn(strings.ToLower(mux.Vars(r)["Name"]))) {
+		hosts[host.Id] = host
 	}
 
-	// subscribe to dnsStorage
-	subscription := env.Storage.Subscribe()
-
-	// setup close handler
-	conn.SetCloseHandler(func(code int, text string) error {
-		env.Storage.Unsubscribe(subscription)
-		return nil
-	})
-
-	// discard incoming messages / close on read error
-	go func() {
-		for {
-			if _, _, err := conn.NextReader(); err != nil {
-				if err := conn.Close(); err != nil {
-					log.Printf("HandleWsHosts error during close: %v", err)
-				}
-				break
-			}
-		}
-	}()
-
-	// send add messages for initial state
-	for hostId, host := range getAllHosts(env) {
-		sendAddMessage(conn, hostId, host)
+	response := filterHosts(hosts, newHostFilter(r.URL.Query()))
+	if len(response) == 0 {
+		return StatusError{404, errors.New("name not found")}
 	}
+	return writeJsonResponse(w, http.StatusOK, response)
+}
 
-	go func() {
-		for {
-			var err error
-
-			select {
-			case newHost, ok := <-subscription.OnAdd:
-				if !ok {
-					return
-				}
-
-				if newHost.Address.IsGlobalUnicast() {
-					sendAddMessage(conn, newHost.Id, convertHost(newHost))
-				}
-			case hostId, ok := <-subscription.OnRemove:
-				if !ok {
-					return
-				}
+// HandleGetContainerHosts lists the hosts of a container given by its id or an unique id prefix
+func HandleGetContainerHosts(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	id := mux.Vars(r)["Id"]
 
-				sendRemoveMessage(conn, hostId)
-			}
-			if err != nil {
-				log.Printf("HandleWsHost: error during conn.WriteJSON: %v", err)
-				_ = conn.Close()
-			}
+	hosts := make(map[string]dnsStorage.Host)
+	containerId := ""
+	for hostId, host := range env.Storage.GetHosts() {
+		if host.Container == nil || !strings.HasPrefix(host.Container.ID, id) {
+			continue
 		}
-	}()
-
-	return nil;
-}
-
-type RemoveMessage struct {
-	Type   string
-	HostId string
-}
+		if containerId != "" && containerId != host.Container.ID {
+			return StatusError{400, errors.New("container id prefix is ambiguous")}
+		}
+		containerId = host.Container.ID
+		hosts[hostId] = host
+	}
 
-type AddMessage struct {
-	Type   string
-	HostId string
-	Host   Host
+	response := filterHosts(hosts, newHostFilter(r.URL.Query()))
+	if len(response) == 0 {
+		return StatusError{404, errors.New("container not found")}
+	}
+	return writeJsonResponse(w, http.StatusOK, response)
 }
 
-func sendAddMessage(conn *websocket.Conn, hostId string, host Host) {
-	err := conn.WriteJSON(AddMessage{
-		Type:   "add",
-		HostId: hostId,
-		Host:   host,
-	})
+func HandleWsHosts(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	if env.isShuttingDown() {
+		return StatusError{503, errors.New("shutting down")}
+	}
 
+	// upgrade to websocket
+	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("sendAddMessage: error during conn.WriteJSON: %v", err)
+		env.Logger.Debug("websocket upgrade failed", "err", err)
+		return nil
 	}
-}
 
-func sendRemoveMessage(conn *websocket.Conn, hostId string) {
-	err := conn.WriteJSON(RemoveMessage{
-		Type:   "remove",
-		HostId: hostId,
-	})
+	client := newWsClient(env, conn, newHostFilter(r.URL.Query()))
+	env.streams.Add(1)
+	go client.readLoop()
+	go func() {
+		defer env.streams.Done()
+		client.writeLoop()
+	}()
 
-	if err != nil {
-		log.Printf("sendRemoveMessage: error during conn.WriteJSON: %v", err)
-	}
+	return nil
 }
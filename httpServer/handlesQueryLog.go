@@ -0,0 +1,16 @@
+package httpServer
+
+import (
+	"net/http"
+
+	"github.com/koestler/dnsdock/resolver"
+)
+
+// HandleGetQueryLog returns the most recent queries answered by the resolver, oldest first
+func HandleGetQueryLog(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	entries := []resolver.QueryLogEntry{}
+	if env.Resolver.QueryLog != nil {
+		entries = env.Resolver.QueryLog.Entries()
+	}
+	return writeJsonResponse(w, http.StatusOK, entries)
+}
@@ -0,0 +1,73 @@
+package httpServer
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/koestler/dnsdock/resolver"
+	"github.com/miekg/dns"
+)
+
+// testResponseWriter records the answer of the resolver
+type testResponseWriter struct {
+	dns.ResponseWriter
+	response *dns.Msg
+}
+
+func (w *testResponseWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP("172.17.0.5"), Port: 40000}
+}
+
+func (w *testResponseWriter) WriteMsg(m *dns.Msg) error {
+	w.response = m
+	return nil
+}
+
+func TestQueryLog(t *testing.T) {
+	env := newTestEnvironment(t)
+	var output bytes.Buffer
+	env.Resolver.QueryLog = resolver.NewQueryLog(2, &output)
+
+	for _, name := range []string{"unknown.docker.", "host.docker.", "host.docker."} {
+		query := new(dns.Msg)
+		query.SetQuestion(name, dns.TypeA)
+		env.Resolver.ServeDNS(&testResponseWriter{}, query)
+	}
+
+	// every query is written as a json line
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 log lines, got %q", output.String())
+	}
+	var first resolver.QueryLogEntry
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Name != "unknown.docker." || first.Rcode != "NXDOMAIN" || first.Client != "172.17.0.5" {
+		t.Errorf("unexpected first entry %+v", first)
+	}
+
+	// the api only keeps the most recent queries
+	w := httptest.NewRecorder()
+	newRouter(nil, env).ServeHTTP(w, httptest.NewRequest("GET", "/api/v0/QueryLog", nil))
+
+	var entries []resolver.QueryLogEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.Name != "host.docker." || entry.Type != "A" || entry.Rcode != "NOERROR" {
+			t.Errorf("unexpected entry %+v", entry)
+		}
+		if len(entry.Answers) != 1 || len(entry.HostIds) != 1 || entry.HostIds[0] != "static_1" {
+			t.Errorf("unexpected answers %v or host ids %v", entry.Answers, entry.HostIds)
+		}
+	}
+}
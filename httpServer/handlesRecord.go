@@ -0,0 +1,43 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/koestler/dnsdock/dnsStorage"
+	"net/http"
+)
+
+func HandlePostRecord(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	var record dnsStorage.Record
+	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+		return StatusError{400, err}
+	}
+
+	host, err := record.Host(dnsStorage.SourceApi)
+	if err != nil {
+		return StatusError{400, err}
+	}
+
+	env.Storage.AddHost(host)
+
+	return writeJsonResponse(w, http.StatusCreated, convertHost(host))
+}
+
+func HandleDeleteRecord(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	id := mux.Vars(r)["Id"]
+
+	host, ok := env.Storage.GetHost(id)
+	if !ok {
+		return StatusError{404, errors.New("record not found")}
+	}
+	if host.Source != dnsStorage.SourceApi {
+		return StatusError{403, errors.New("only records created through the api can be deleted")}
+	}
+
+	env.Storage.RemoveHost(id)
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,32 @@
+package httpServer
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ReloadResponse describes the changes applied by a reload of the configuration
+type ReloadResponse struct {
+	StaticRecordsAdded   int
+	StaticRecordsRemoved int
+	LogLevel             string
+	Ttl                  uint32
+	Naming               string
+	ConflictPolicy       string
+	NetworkScope         string
+	// Errors lists the invalid static records which were skipped
+	Errors []string `json:",omitempty"`
+}
+
+// HandleReload reloads the configuration file, like sending SIGHUP
+func HandleReload(env *Environment, w http.ResponseWriter, r *http.Request) Error {
+	if env.Reload == nil {
+		return StatusError{501, errors.New("reload not supported")}
+	}
+
+	response, err := env.Reload()
+	if err != nil {
+		return StatusError{500, err}
+	}
+	return writeJsonResponse(w, http.StatusOK, response)
+}
@@ -0,0 +1,48 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	env := newTestEnvironment(t)
+	router := newRouter(nil, env)
+
+	post := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v0/reload", nil))
+		return w
+	}
+
+	if w := post(); w.Code != http.StatusNotImplemented {
+		t.Errorf("expected status 501 without reload support, got %d", w.Code)
+	}
+
+	env.Reload = func() (ReloadResponse, error) {
+		return ReloadResponse{StaticRecordsAdded: 2, LogLevel: "INFO"}, nil
+	}
+	w := post()
+	var response ReloadResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if response.StaticRecordsAdded != 2 || response.LogLevel != "INFO" {
+		t.Errorf("unexpected reload result %+v", response)
+	}
+
+	env.Reload = func() (ReloadResponse, error) {
+		return ReloadResponse{}, errors.New("invalid log level")
+	}
+	var errorResponse ErrorResponse
+	w = post()
+	if err := json.Unmarshal(w.Body.Bytes(), &errorResponse); err != nil || w.Code != http.StatusInternalServerError {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if errorResponse.Message != "invalid log level" {
+		t.Errorf("unexpected error message %q", errorResponse.Message)
+	}
+}
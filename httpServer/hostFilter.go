@@ -0,0 +1,90 @@
+package httpServer
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/koestler/dnsdock/dnsStorage"
+)
+
+// compose sets this label to the project name
+const composeProjectLabel = "com.docker.compose.project"
+
+// hostFilter selects hosts by the query parameters network, project, image and
+// label. label may be given multiple times, either as key or as key=value.
+type hostFilter struct {
+	network string
+	project string
+	image   string
+	labels  []string
+}
+
+func newHostFilter(query url.Values) hostFilter {
+	return hostFilter{
+		network: query.Get("network"),
+		project: query.Get("project"),
+		image:   query.Get("image"),
+		labels:  query["label"],
+	}
+}
+
+func (f hostFilter) matches(host dnsStorage.Host) bool {
+	if f.network != "" && host.Network != f.network {
+		return false
+	}
+
+	if f.project == "" && f.image == "" && len(f.labels) == 0 {
+		return true
+	}
+
+	// project, image and label filters only match containers
+	container := host.Container
+	if container == nil || container.Config == nil {
+		return false
+	}
+
+	if f.project != "" && container.Config.Labels[composeProjectLabel] != f.project {
+		return false
+	}
+
+	if f.image != "" && !matchesImage(f.image, container.Config.Image, container.Image) {
+		return false
+	}
+
+	for _, label := range f.labels {
+		key, value := label, ""
+		hasValue := false
+		if i := strings.Index(label, "="); i >= 0 {
+			key, value, hasValue = label[:i], label[i+1:], true
+		}
+
+		actual, ok := container.Config.Labels[key]
+		if !ok || (hasValue && actual != value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesImage compares the filter against the image name with or without
+// tag and against the image id
+func matchesImage(filter, name, id string) bool {
+	if filter == name || filter == id || strings.TrimPrefix(id, "sha256:") == filter {
+		return true
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		return filter == name[:i]
+	}
+	return false
+}
+
+func filterHosts(hosts map[string]dnsStorage.Host, filter hostFilter) (response map[string]Host) {
+	response = make(map[string]Host, len(hosts))
+	for id, host := range hosts {
+		if isVisible(host) && filter.matches(host) {
+			response[id] = convertHost(host)
+		}
+	}
+	return
+}
@@ -4,14 +4,20 @@ import (
 	"github.com/fsouza/go-dockerclient"
 	"github.com/koestler/dnsdock/dnsStorage"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Host struct {
+	Id        string
+	Source    string
 	Name      string
-	Address   string
+	Address   string `json:",omitempty"`
+	Network   string `json:",omitempty"`
 	Aliases   []string
-	Container Container
+	Target    string     `json:",omitempty"`
+	Text      []string   `json:",omitempty"`
+	Container *Container `json:",omitempty"`
 	Ports     []Port
 }
 
@@ -21,45 +27,65 @@ type Port struct {
 }
 
 type Container struct {
-	ID      string         `json:"Id"`
-	Created time.Time      `json:"Created,omitempty"`
-	Image   string         `json:"Image,omitempty"`
-	Mounts  []docker.Mount `json:"Mounts,omitempty"`
+	ID      string    `json:"Id"`
+	Name    string    `json:"Name,omitempty"`
+	Created time.Time `json:"Created,omitempty"`
+	Image   string    `json:"Image,omitempty"`
+	// ImageName is the image as given when creating the container, e.g. nginx:latest
+	ImageName string            `json:"ImageName,omitempty"`
+	Labels    map[string]string `json:"Labels,omitempty"`
+	Mounts    []docker.Mount    `json:"Mounts,omitempty"`
 }
 
 func getAllHosts(env *Environment) (response map[string]Host) {
-	hosts := env.Storage.GetHosts()
-	response = make(map[string]Host, len(hosts))
+	return filterHosts(env.Storage.GetHosts(), hostFilter{})
+}
 
-	for id, host := range hosts {
-		if !host.Address.IsGlobalUnicast() {
-			continue
-		}
-		response[id] = convertHost(host)
-	}
-	return
+// isVisible hides container hosts without a routable address
+func isVisible(host dnsStorage.Host) bool {
+	return host.Source != dnsStorage.SourceContainer || host.Address.IsGlobalUnicast()
 }
 
-func convertHost(host dnsStorage.Host) (Host) {
-	return Host{
-		Name:      host.Name,
-		Address:   host.Address.String(),
-		Aliases:   host.Aliases,
-		Container: convertContainer(host.Container),
-		Ports:     convertPorts(host.Container.NetworkSettings.Ports),
+func convertHost(host dnsStorage.Host) Host {
+	ret := Host{
+		Id:      host.Id,
+		Source:  host.Source,
+		Name:    host.Name,
+		Network: host.Network,
+		Aliases: host.Aliases,
+		Target:  host.Target,
+		Text:    host.Text,
+		Ports:   []Port{},
+	}
+	if host.Address != nil {
+		ret.Address = host.Address.String()
+	}
+	if host.Container != nil {
+		ret.Container = convertContainer(host.Container)
 	}
+	// containers restored from a snapshot have no network settings
+	if host.Container != nil && host.Container.NetworkSettings != nil {
+		ret.Ports = convertPorts(host.Container.NetworkSettings.Ports)
+	}
+	return ret
 }
 
-func convertContainer(container *docker.Container) (Container) {
-	return Container{
+func convertContainer(container *docker.Container) *Container {
+	ret := &Container{
 		ID:      container.ID,
+		Name:    strings.TrimPrefix(container.Name, "/"),
 		Created: container.Created,
 		Image:   container.Image,
 		Mounts:  container.Mounts,
 	}
+	if container.Config != nil {
+		ret.ImageName = container.Config.Image
+		ret.Labels = container.Config.Labels
+	}
+	return ret
 }
 
-func convertPorts(ports map[docker.Port][]docker.PortBinding) ([]Port) {
+func convertPorts(ports map[docker.Port][]docker.PortBinding) []Port {
 	ret := make([]Port, len(ports))
 
 	i := 0
@@ -1,18 +1,62 @@
 package httpServer
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
-	"os"
 	"strconv"
 )
 
-func Run(bind string, port int, env *Environment) {
-	go func() {
-		router := newRouter(os.Stdout, env)
-		address := bind + ":" + strconv.Itoa(port)
+// Server serves the http api until it is shut down
+type Server struct {
+	server *http.Server
+	env    *Environment
+	logger *slog.Logger
+}
+
+func NewServer(bind string, port int, env *Environment) *Server {
+	logger := env.Logger.With("component", "httpServer")
+
+	// streaming handlers watch this channel to end their streams on shutdown
+	env.shutdown = make(chan struct{})
+
+	server := &http.Server{
+		Addr:    bind + ":" + strconv.Itoa(port),
+		Handler: newRouter(logger, env),
+	}
+	server.RegisterOnShutdown(func() {
+		close(env.shutdown)
+	})
+
+	return &Server{server: server, env: env, logger: logger}
+}
+
+// Run blocks until the server fails or is shut down
+func (s *Server) Run() error {
+	s.logger.Info("listening", "address", s.server.Addr)
+	if err := s.server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
 
-		log.Printf("httpServer: listening on %v", address)
-		log.Fatal(router, http.ListenAndServe(address, router))
+// Shutdown stops accepting connections, waits for pending requests and closes
+// websocket and event streams until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.server.Shutdown(ctx)
+
+	streamsDone := make(chan struct{})
+	go func() {
+		s.env.streams.Wait()
+		close(streamsDone)
 	}()
+
+	select {
+	case <-streamsDone:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
 }
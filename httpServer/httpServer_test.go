@@ -0,0 +1,45 @@
+package httpServer
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestShutdownClosesWebsockets(t *testing.T) {
+	s := NewServer("127.0.0.1", 0, newTestEnvironment(t))
+	server := httptest.NewServer(s.server.Handler)
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/ws/Hosts", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if msg := readWsMessage(t, conn); msg["Type"] != "add" {
+		t.Fatalf("expected initial add, got %v", msg)
+	}
+
+	// answer the close message while shutting down
+	readErr := make(chan error, 1)
+	go func() {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, _, err := conn.ReadMessage()
+		readErr <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	if err := <-readErr; !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected a going away close message, got %v", err)
+	}
+}
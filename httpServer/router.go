@@ -2,9 +2,9 @@ package httpServer
 
 import (
 	"github.com/gorilla/mux"
-	"github.com/lestrrat-go/apache-logformat"
-	"io"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
 type WsRoute struct {
@@ -13,6 +13,7 @@ type WsRoute struct {
 	HandlerFunc HandlerHandleFunc
 }
 
+// HttpRoute matches requests of Method, or of all methods if it is empty
 type HttpRoute struct {
 	Name        string
 	Method      string
@@ -23,7 +24,7 @@ type HttpRoute struct {
 type WsRoutes []WsRoute
 type HttpRoutes []HttpRoute
 
-func newRouter(logger io.Writer, env *Environment) *mux.Router {
+func newRouter(logger *slog.Logger, env *Environment) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
 
 	// setup websocket routes
@@ -41,14 +42,66 @@ func newRouter(logger io.Writer, env *Environment) *mux.Router {
 		var handler http.Handler
 		handler = Handler{Env: env, Handle: route.HandlerFunc}
 		if logger != nil {
-			handler = apachelog.CombinedLog.Wrap(handler, logger)
+			handler = accessLog(handler, logger)
 		}
 
-		router.Methods(route.Method).
-			Path(route.Pattern).
-			Name(route.Name).
-			Handler(handler)
+		r := router.NewRoute()
+		if route.Method == "" {
+			// catch-alls leave known paths requested with another method to
+			// the MethodNotAllowedHandler
+			r.MatcherFunc(func(req *http.Request, match *mux.RouteMatch) bool {
+				return match.MatchErr != mux.ErrMethodMismatch
+			}).Path(route.Pattern)
+		} else {
+			// the path is matched first, mux forgets a method mismatch when
+			// only the method of a later route matches
+			r.Path(route.Pattern).Methods(route.Method)
+		}
+		r.Name(route.Name).Handler(handler)
 	}
 
+	router.NotFoundHandler = jsonErrorHandler(http.StatusNotFound, logger)
+	router.MethodNotAllowedHandler = jsonErrorHandler(http.StatusMethodNotAllowed, logger)
+
 	return router
 }
+
+// jsonErrorHandler answers all requests with an ErrorResponse of the given status
+func jsonErrorHandler(status int, logger *slog.Logger) http.Handler {
+	var handler http.Handler
+	handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJsonError(w, status, http.StatusText(status))
+	})
+	if logger != nil {
+		handler = accessLog(handler, logger)
+	}
+	return handler
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// accessLog logs every request handled by next
+func accessLog(next http.Handler, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.RequestURI(),
+			"status", recorder.status,
+			"remote", r.RemoteAddr,
+			"duration", time.Since(start),
+		)
+	})
+}
@@ -0,0 +1,122 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koestler/dnsdock/dnsStorage"
+	"github.com/koestler/dnsdock/health"
+	"github.com/koestler/dnsdock/resolver"
+)
+
+func newTestEnvironment(t *testing.T) *Environment {
+	storage := dnsStorage.NewDnsStorage(slog.Default())
+
+	subscription := storage.Subscribe()
+	storage.AddHost(dnsStorage.Host{
+		Id:      "static_1",
+		Name:    "host.docker",
+		Address: net.ParseIP("10.0.0.1"),
+		Source:  dnsStorage.SourceStatic,
+	})
+	select {
+	case <-subscription.Changes:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for add")
+	}
+
+	dnsResolver, err := resolver.NewResolver(storage, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := health.NewStatus(health.ConditionDns)
+	dnsResolver.Health = status
+	dnsResolver.SelfCheckName = "_dnsdock.docker"
+
+	return &Environment{Storage: storage, Resolver: dnsResolver, Logger: slog.Default(), Health: status}
+}
+
+func TestRoutes(t *testing.T) {
+	router := newRouter(nil, newTestEnvironment(t))
+
+	tests := []struct {
+		method      string
+		path        string
+		status      int
+		contentType string
+	}{
+		{"GET", "/api/v0/Hosts", 200, "application/json"},
+		{"GET", "/api/v1/Hosts", 200, "application/json"},
+		{"GET", "/api/v1/Hosts/static_1", 200, "application/json"},
+		{"GET", "/api/v1/Hosts/unknown", 404, "application/json"},
+		{"GET", "/api/v1/Names/host.docker", 200, "application/json"},
+		{"GET", "/api/v1/Names/unknown.docker", 404, "application/json"},
+		{"GET", "/api/v2/Hosts", 404, "application/json"},
+		{"GET", "/api/v1/openapi.json", 200, "application/json"},
+		{"GET", "/api", 200, "text/html"},
+		{"GET", "/", 200, "text/html"},
+		{"GET", "/api/v1/Resolve?name=host.docker", 200, "application/json"},
+		{"GET", "/api/v1/Resolve?name=host.docker&type=BOGUS", 400, "application/json"},
+		{"GET", "/api/v0/QueryLog", 200, "application/json"},
+		{"GET", "/api/v0/Conflicts", 200, "application/json"},
+		{"GET", "/metrics", 200, "text/plain"},
+		{"GET", "/healthz", 200, "application/json"},
+		{"DELETE", "/api/v1/Hosts", 405, "application/json"},
+		{"POST", "/api/v1/Hosts/static_1", 405, "application/json"},
+		{"POST", "/api/v1/unknown", 404, "application/json"},
+		{"DELETE", "/api/v2/Records/static_1", 404, "application/json"},
+		{"GET", "/unknown", 404, "application/json"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+		if w.Code != test.status {
+			t.Errorf("%s %s: expected status %d, got %d", test.method, test.path, test.status, w.Code)
+		}
+
+		contentType := w.Header().Get("Content-Type")
+		if !strings.HasPrefix(contentType, test.contentType) {
+			t.Errorf("%s %s: expected content type %s, got %s", test.method, test.path, test.contentType, contentType)
+		}
+
+		if w.Code >= 400 {
+			var body ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != test.status || body.Message == "" {
+				t.Errorf("%s %s: unexpected error body %q", test.method, test.path, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestPostRecord(t *testing.T) {
+	router := newRouter(nil, newTestEnvironment(t))
+
+	w := httptest.NewRecorder()
+	body := `{"Name": "db.docker", "Type": "CNAME", "Value": "host.docker"}`
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/Records", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	body = `{"Name": "db.docker", "Type": "A", "Value": "not an address"}`
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/Records", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/v1/Records/static_1", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 deleting a static record, got %d", w.Code)
+	}
+}
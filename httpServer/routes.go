@@ -1,23 +1,122 @@
 package httpServer
 
+// v1 is the documented api, v0 is kept for existing clients
+const apiPrefix = "/api/{Version:v[01]}"
+
+// streaming routes, these are not wrapped by the access logger
 var wsRoutes = WsRoutes{
 	WsRoute{
 		"hosts",
-		"/api/v0/ws/Hosts",
+		apiPrefix + "/ws/Hosts",
 		HandleWsHosts,
 	},
+	WsRoute{
+		"events",
+		apiPrefix + "/events",
+		HandleEvents,
+	},
 }
 
 var httpRoutes = HttpRoutes{
 	HttpRoute{
 		"HostsAll",
 		"GET",
-		"/api/v0/Hosts",
+		apiPrefix + "/Hosts",
 		HandleGetHosts,
 	},
+	HttpRoute{
+		"Host",
+		"GET",
+		apiPrefix + "/Hosts/{Id}",
+		HandleGetHost,
+	},
+	HttpRoute{
+		"NameHosts",
+		"GET",
+		apiPrefix + "/Names/{Name}",
+		HandleGetName,
+	},
+	HttpRoute{
+		"ContainerHosts",
+		"GET",
+		apiPrefix + "/Containers/{Id}/Hosts",
+		HandleGetContainerHosts,
+	},
+	HttpRoute{
+		"Conflicts",
+		"GET",
+		apiPrefix + "/Conflicts",
+		HandleGetConflicts,
+	},
+	HttpRoute{
+		"RecordCreate",
+		"POST",
+		apiPrefix + "/Records",
+		HandlePostRecord,
+	},
+	HttpRoute{
+		"RecordDelete",
+		"DELETE",
+		apiPrefix + "/Records/{Id}",
+		HandleDeleteRecord,
+	},
+	HttpRoute{
+		"Resolve",
+		"GET",
+		apiPrefix + "/Resolve",
+		HandleResolve,
+	},
+	HttpRoute{
+		"Reload",
+		"POST",
+		apiPrefix + "/reload",
+		HandleReload,
+	},
+	HttpRoute{
+		"QueryLog",
+		"GET",
+		apiPrefix + "/QueryLog",
+		HandleGetQueryLog,
+	},
+	HttpRoute{
+		"Dashboard",
+		"GET",
+		"/",
+		HandleDashboard,
+	},
+	HttpRoute{
+		"Healthz",
+		"GET",
+		"/healthz",
+		HandleHealthz,
+	},
+	HttpRoute{
+		"Readyz",
+		"GET",
+		"/readyz",
+		HandleReadyz,
+	},
+	HttpRoute{
+		"Metrics",
+		"GET",
+		"/metrics",
+		HandleMetrics,
+	},
+	HttpRoute{
+		"OpenApi",
+		"GET",
+		"/api/v1/openapi.json",
+		HandleOpenApi,
+	},
 	HttpRoute{
 		"ApiIndex",
 		"GET",
+		"/api",
+		HandleApiIndex,
+	},
+	HttpRoute{
+		"ApiNotFound",
+		"",
 		"/api{Path:.*}",
 		HandleApiNotFound,
 	},
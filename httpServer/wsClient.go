@@ -0,0 +1,250 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/koestler/dnsdock/dnsStorage"
+)
+
+const (
+	// time allowed to write a message to the client
+	wsWriteWait = 10 * time.Second
+	// time allowed to read the next pong message from the client
+	wsPongWait = 60 * time.Second
+	// send pings to the client with this period, must be less than wsPongWait
+	wsPingPeriod = wsPongWait * 9 / 10
+	// time allowed for the client to answer a close message
+	wsCloseWait = time.Second
+)
+
+// ClientMessage is sent by websocket clients. Type is one of
+// - subscribe: replace the filter and resend all matching hosts
+// - resync: resend all matching hosts
+// - ping: answered by a pong message
+type ClientMessage struct {
+	Type   string
+	Filter *ClientFilter `json:",omitempty"`
+}
+
+// ClientFilter restricts the hosts sent to a client, empty fields match all
+type ClientFilter struct {
+	Network string
+	Project string
+	Image   string
+	Label   []string
+}
+
+type RemoveMessage struct {
+	Type   string
+	HostId string
+}
+
+type AddMessage struct {
+	Type   string
+	HostId string
+	Host   Host
+}
+
+// StatusMessage is used for the reset, pong and error messages
+type StatusMessage struct {
+	Type    string
+	Message string `json:",omitempty"`
+}
+
+// wsClient streams the hosts to a websocket. All writes happen in writeLoop,
+// which owns the subscription; readLoop only forwards client messages.
+type wsClient struct {
+	env    *Environment
+	conn   *websocket.Conn
+	filter hostFilter
+
+	commands chan ClientMessage
+	closed   chan struct{}
+
+	// hosts sent to the client, to send removes for hosts no longer matching
+	sent map[string]bool
+}
+
+func newWsClient(env *Environment, conn *websocket.Conn, filter hostFilter) *wsClient {
+	return &wsClient{
+		env:      env,
+		conn:     conn,
+		filter:   filter,
+		commands: make(chan ClientMessage, 4),
+		closed:   make(chan struct{}),
+		sent:     make(map[string]bool),
+	}
+}
+
+func (c *wsClient) readLoop() {
+	defer close(c.closed)
+
+	c.conn.SetReadLimit(4096)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var msg ClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			msg = ClientMessage{Type: "invalid"}
+		}
+
+		select {
+		case c.commands <- msg:
+		case <-time.After(wsWriteWait):
+			// the writer is stuck, give up
+			return
+		}
+	}
+}
+
+func (c *wsClient) writeLoop() {
+	subscription := c.env.Storage.Subscribe()
+	defer func() {
+		c.env.Storage.Unsubscribe(subscription)
+		if err := c.conn.Close(); err != nil {
+			c.env.Logger.Debug("websocket close failed", "err", err)
+		}
+	}()
+
+	if err := c.sendSnapshot(subscription.Snapshot, false); err != nil {
+		return
+	}
+
+	pingTicker := time.NewTicker(wsPingPeriod)
+	defer pingTicker.Stop()
+
+	revision := subscription.Revision
+	for {
+		var err error
+
+		select {
+		case change, ok := <-subscription.Changes:
+			if !ok {
+				// fell behind, resume from the last change seen
+				subscription = c.env.Storage.SubscribeSince(revision)
+				err = c.sendResumed(subscription)
+				revision = subscription.Revision
+				break
+			}
+			revision = change.Revision
+			err = c.sendChange(change)
+		case cmd := <-c.commands:
+			switch cmd.Type {
+			case "subscribe":
+				if cmd.Filter != nil {
+					c.filter = hostFilter{
+						network: cmd.Filter.Network,
+						project: cmd.Filter.Project,
+						image:   cmd.Filter.Image,
+						labels:  cmd.Filter.Label,
+					}
+				} else {
+					c.filter = hostFilter{}
+				}
+				subscription, err = c.resubscribe(subscription)
+				revision = subscription.Revision
+			case "resync":
+				subscription, err = c.resubscribe(subscription)
+				revision = subscription.Revision
+			case "ping":
+				err = c.write(StatusMessage{Type: "pong"})
+			default:
+				err = c.write(StatusMessage{Type: "error", Message: "unknown message type: " + cmd.Type})
+			}
+		case <-pingTicker.C:
+			err = c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+		case <-c.closed:
+			return
+		case <-c.env.shutdown:
+			c.closeGoingAway()
+			return
+		}
+
+		if err != nil {
+			c.env.Logger.Debug("websocket write failed, closing connection", "err", err)
+			return
+		}
+	}
+}
+
+// closeGoingAway sends a close message and waits for the client to answer it
+func (c *wsClient) closeGoingAway() {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	if err := c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteWait)); err != nil {
+		return
+	}
+	select {
+	case <-c.closed:
+	case <-time.After(wsCloseWait):
+	}
+}
+
+// resubscribe replaces the subscription by a fresh one and sends its snapshot
+func (c *wsClient) resubscribe(old *dnsStorage.Subscription) (*dnsStorage.Subscription, error) {
+	c.env.Storage.Unsubscribe(old)
+	subscription := c.env.Storage.Subscribe()
+	return subscription, c.sendSnapshot(subscription.Snapshot, true)
+}
+
+func (c *wsClient) write(v interface{}) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait)); err != nil {
+		return err
+	}
+	return c.conn.WriteJSON(v)
+}
+
+// sendSnapshot sends add messages for all matching hosts. On reset the
+// client is told to drop its state first.
+func (c *wsClient) sendSnapshot(hosts dnsStorage.Hosts, reset bool) error {
+	if reset {
+		c.sent = make(map[string]bool)
+		if err := c.write(StatusMessage{Type: "reset"}); err != nil {
+			return err
+		}
+	}
+
+	for hostId, host := range filterHosts(hosts, c.filter) {
+		c.sent[hostId] = true
+		if err := c.write(AddMessage{Type: "add", HostId: hostId, Host: host}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *wsClient) sendResumed(subscription *dnsStorage.Subscription) error {
+	if subscription.Snapshot != nil {
+		return c.sendSnapshot(subscription.Snapshot, true)
+	}
+	for _, change := range subscription.Backlog {
+		if err := c.sendChange(change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *wsClient) sendChange(change dnsStorage.Change) error {
+	if change.Type != dnsStorage.ChangeRemove && isVisible(change.Host) && c.filter.matches(change.Host) {
+		c.sent[change.HostId] = true
+		return c.write(AddMessage{Type: "add", HostId: change.HostId, Host: convertHost(change.Host)})
+	}
+
+	// removed or no longer matching
+	if c.sent[change.HostId] {
+		delete(c.sent, change.HostId)
+		return c.write(RemoveMessage{Type: "remove", HostId: change.HostId})
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package httpServer
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func readWsMessage(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var msg map[string]interface{}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestWsHosts(t *testing.T) {
+	server := httptest.NewServer(newRouter(nil, newTestEnvironment(t)))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/ws/Hosts", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if msg := readWsMessage(t, conn); msg["Type"] != "add" || msg["HostId"] != "static_1" {
+		t.Fatalf("expected initial add of static_1, got %v", msg)
+	}
+
+	if err := conn.WriteJSON(ClientMessage{Type: "ping"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readWsMessage(t, conn); msg["Type"] != "pong" {
+		t.Fatalf("expected pong, got %v", msg)
+	}
+
+	// static_1 is on no network, hence filtered
+	if err := conn.WriteJSON(ClientMessage{Type: "subscribe", Filter: &ClientFilter{Network: "other"}}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readWsMessage(t, conn); msg["Type"] != "reset" {
+		t.Fatalf("expected reset, got %v", msg)
+	}
+
+	if err := conn.WriteJSON(ClientMessage{Type: "resync"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readWsMessage(t, conn); msg["Type"] != "reset" {
+		t.Fatalf("expected reset, got %v", msg)
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readWsMessage(t, conn); msg["Type"] != "error" {
+		t.Fatalf("expected error, got %v", msg)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"reflect"
+	"sync"
+
+	dockerapi "github.com/fsouza/go-dockerclient"
+	"github.com/koestler/dnsdock/hostIntegration"
+	"github.com/koestler/dnsdock/resolver"
+)
+
+// integration keeps the host's resolver configuration and the reverse zones
+// of the dns resolver in sync with the docker networks
+type integration struct {
+	docker   *dockerapi.Client
+	backends []hostIntegration.Backend
+	resolver *resolver.DnsResolver
+	address  string
+	domain   string
+	logger   *slog.Logger
+
+	mutex sync.Mutex
+	zones []string
+}
+
+func ipAddress() (string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+
+	for _, address := range addrs {
+		if ipnet, ok := address.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && !ipnet.IP.IsMulticast() {
+			if ipv4 := ipnet.IP.To4(); ipv4 != nil {
+				return ipv4.String(), nil
+			}
+		}
+	}
+
+	return "", errors.New("no addresses found")
+}
+
+// networkSubnets returns the IPAM subnets of all docker networks by network
+// name and the gateways of the networks
+func networkSubnets(docker *dockerapi.Client, logger *slog.Logger) (subnets map[string][]*net.IPNet, gateways []net.IP, err error) {
+	networks, err := docker.ListNetworks()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subnets = make(map[string][]*net.IPNet)
+	for _, network := range networks {
+		for _, config := range network.IPAM.Config {
+			if config.Subnet == "" {
+				continue
+			}
+			_, subnet, err := net.ParseCIDR(config.Subnet)
+			if err != nil {
+				logger.Warn("invalid subnet", "network", network.Name, "err", err)
+				continue
+			}
+			subnets[network.Name] = append(subnets[network.Name], subnet)
+
+			if gateway := net.ParseIP(config.Gateway); gateway != nil {
+				gateways = append(gateways, gateway)
+			} else if config.Gateway == "" {
+				// docker takes the first address of the subnet by default
+				gateway := make(net.IP, len(subnet.IP))
+				copy(gateway, subnet.IP)
+				gateway[len(gateway)-1]++
+				gateways = append(gateways, gateway)
+			}
+		}
+	}
+	return
+}
+
+// update passes the subnets of the networks to the resolver, recomputes the
+// reverse zones and rewrites the integration configuration if they changed
+func (i *integration) update() error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	subnets, gateways, err := networkSubnets(i.docker, i.logger)
+	if err != nil {
+		return err
+	}
+	i.resolver.SetNetworks(subnets, gateways)
+
+	var allSubnets []*net.IPNet
+	for _, networkSubnets := range subnets {
+		allSubnets = append(allSubnets, networkSubnets...)
+	}
+	zones := hostIntegration.ReverseZones(allSubnets)
+	if i.zones != nil && reflect.DeepEqual(zones, i.zones) {
+		return nil
+	}
+	i.resolver.SetReverseZones(zones)
+
+	if len(i.backends) == 0 {
+		i.zones = zones
+		return nil
+	}
+
+	if i.address == "" {
+		if i.address, err = ipAddress(); err != nil {
+			return err
+		}
+		i.logger.Info("got local address", "address", i.address)
+	}
+
+	settings := hostIntegration.Settings{
+		Address:      i.address,
+		Domain:       i.domain,
+		ReverseZones: zones,
+	}
+
+	for _, backend := range i.backends {
+		i.logger.Info("write configuration", "backend", backend.Name(), "zones", settings.ReverseZones)
+		if err := backend.Write(settings); err != nil {
+			return err
+		}
+	}
+
+	i.zones = zones
+	return nil
+}
+
+// number of docker events buffered per listener, the docker client drops
+// events for listeners which are not ready to receive
+const dockerEventBuffer = 256
+
+// networkChanged reports whether msg creates or removes a network
+func networkChanged(msg *dockerapi.APIEvents) bool {
+	return msg.Type == "network" && (msg.Action == "create" || msg.Action == "destroy")
+}
+
+// watch updates the configuration whenever a docker network is created or
+// removed until ctx is done
+func (i *integration) watch(ctx context.Context) error {
+	events := make(chan *dockerapi.APIEvents, dockerEventBuffer)
+	if err := i.docker.AddEventListener(events); err != nil {
+		return err
+	}
+
+	for {
+		var msg *dockerapi.APIEvents
+		select {
+		case msg = <-events:
+		case <-ctx.Done():
+			i.docker.RemoveEventListener(events)
+			return nil
+		}
+		if msg == nil {
+			return errors.New("docker network event loop closed")
+		}
+		if !networkChanged(msg) {
+			continue
+		}
+		i.logger.Debug("network changed, update reverse zones", "action", msg.Action, "network", msg.Actor.ID)
+
+		// a single update covers all changes queued meanwhile
+	drain:
+		for {
+			select {
+			case queued := <-events:
+				if queued == nil {
+					return errors.New("docker network event loop closed")
+				}
+			default:
+				break drain
+			}
+		}
+
+		if err := i.update(); err != nil {
+			i.logger.Error("could not update dns integration", "err", err)
+		}
+	}
+}
+
+func (i *integration) remove() {
+	for _, backend := range i.backends {
+		if err := backend.Remove(); err != nil {
+			i.logger.Error("could not remove configuration", "backend", backend.Name(), "err", err)
+		}
+	}
+}
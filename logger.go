@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// parseLogLevel accepts debug, info, warn and error
+func parseLogLevel(name string) (level slog.Level, err error) {
+	err = level.UnmarshalText([]byte(name))
+	if err != nil {
+		err = fmt.Errorf("invalid log level %q", name)
+	}
+	return
+}
+
+// newLogger creates the logger writing to stderr in the given format, text or json.
+// The returned level can be changed at runtime.
+func newLogger(levelName, format string) (*slog.Logger, *slog.LevelVar, error) {
+	level := new(slog.LevelVar)
+	l, err := parseLogLevel(levelName)
+	if err != nil {
+		return nil, nil, err
+	}
+	level.Set(l)
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return nil, nil, fmt.Errorf("invalid log format %q", format)
+	}
+
+	return slog.New(handler), level, nil
+}
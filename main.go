@@ -1,14 +1,25 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/koestler/dnsdock/containerFilter"
 	"github.com/koestler/dnsdock/dnsStorage"
-	"log"
+	"github.com/koestler/dnsdock/health"
+	"github.com/koestler/dnsdock/hostIntegration"
+	"github.com/koestler/dnsdock/hostsFile"
+	"github.com/koestler/dnsdock/metrics"
+	"github.com/koestler/dnsdock/naming"
+	"io"
+	"log/slog"
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/koestler/dnsdock/httpServer"
 	"github.com/koestler/dnsdock/resolver"
@@ -33,69 +44,293 @@ func main() {
 		fmt.Println("build at:", buildTime)
 		os.Exit(0)
 	}
-	log.Printf("Starting koestler-dnsdock %s ...", buildVersion)
 
-	err := run()
+	logger, logLevel, err := newLogger(getopt("LOG_LEVEL", "info"), getopt("LOG_FORMAT", "text"))
 	if err != nil {
-		log.Fatal("dnsdock: ", err)
+		fmt.Fprintln(os.Stderr, "dnsdock:", err)
+		os.Exit(1)
+	}
+	// libraries using the log package end up in the same output
+	slog.SetDefault(logger)
+
+	logger.Info("starting koestler-dnsdock", "version", buildVersion)
+
+	err = run(logger, logLevel)
+	if err != nil {
+		logger.Error("dnsdock exited", "err", err)
+		os.Exit(1)
 	}
 }
 
-func run() error {
+func run(logger *slog.Logger, logLevel *slog.LevelVar) error {
 	// set up the signal handler first to ensure cleanup is handled if a signal is
 	// caught while initializing
-	exitReason := make(chan error)
-	go func() {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
-		sig := <-c
-		log.Println("exit requested by signal:", sig)
-		exitReason <- nil
-	}()
+	signalCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// all subsystems run until ctx is done, the first one failing stops the others
+	ctx, cancel := context.WithCancel(signalCtx)
+	defer cancel()
+	exitReason := make(chan error, 1)
+	fail := func(err error) {
+		select {
+		case exitReason <- err:
+		default:
+		}
+		cancel()
+	}
+	var workers sync.WaitGroup
+	start := func(f func() error) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			if err := f(); err != nil {
+				fail(err)
+			}
+			cancel()
+		}()
+	}
+
+	shutdownTimeout, err := time.ParseDuration(getopt("SHUTDOWN_TIMEOUT", "10s"))
+	if err != nil {
+		return fmt.Errorf("invalid SHUTDOWN_TIMEOUT: %v", err)
+	}
+
+	config, err := readConfig(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return err
+	}
 
 	docker, err := dockerapi.NewClient(getopt("DOCKER_HOST", "unix:///var/run/docker.sock"))
 	if err != nil {
 		return err
 	}
 
-	if err := writeDnsmasqd(); err != nil {
-		log.Printf("[ERROR] could not write dnsmasq conf: %v", err)
+	localDomain := os.Getenv("LOCAL_DOMAIN")
+	if localDomain == "" {
+		localDomain = "docker"
 	}
 
 	var hostIP net.IP
 	if envHostIP := os.Getenv("HOST_IP"); envHostIP != "" {
-		hostIP = net.ParseIP(envHostIP)
-		log.Println("using address for --net=host:", hostIP)
+		if hostIP = net.ParseIP(envHostIP); hostIP == nil {
+			return fmt.Errorf("invalid HOST_IP: %s", envHostIP)
+		}
+		logger.Info("using address for --net=host", "address", hostIP)
 	}
 
 	// create dnsStorage
-	storage := dnsStorage.NewDnsStorage()
+	var storage *dnsStorage.DnsStorage
+	if path := os.Getenv("STORAGE_FILE"); path != "" {
+		if storage, err = dnsStorage.NewPersistentDnsStorage(path, logger); err != nil {
+			return err
+		}
+	} else {
+		storage = dnsStorage.NewDnsStorage(logger)
+	}
+	metrics.RegisterStorage(storage)
 
 	// dns dnsResolver
-	dnsResolver, err := resolver.NewResolver(storage)
+	dnsResolver, err := resolver.NewResolver(storage, logger)
 	if err != nil {
 		return err
 	}
 	defer dnsResolver.Close()
 
-	localDomain := os.Getenv("LOCAL_DOMAIN")
-	if localDomain == "" {
-		localDomain = "docker"
+	// apply the configuration, reloaded on SIGHUP or by the api
+	reloader := &reloader{
+		path:            os.Getenv("CONFIG_FILE"),
+		storage:         storage,
+		resolver:        dnsResolver,
+		logger:          logger,
+		logLevel:        logLevel,
+		defaultLogLevel: logLevel.Level(),
+		names:           naming.NewSelector(nil),
+		defaultNaming: naming.Config{
+			Strategy: getopt("NAMING_STRATEGY", "compose"),
+			Template: os.Getenv("NAMING_TEMPLATE"),
+		},
+		zone:                  localDomain,
+		defaultConflictPolicy: getopt("CONFLICT_POLICY", dnsStorage.ConflictAllow),
+		defaultNetworkScope:   getopt("NETWORK_SCOPE", resolver.ScopePrefer),
+		filters:               containerFilter.NewSelector(nil),
+	}
+	if _, err := reloader.apply(config); err != nil {
+		return err
+	}
+	storage.PruneRestored(dnsStorage.SourceStatic)
+
+	// readiness, optionally answered by the resolver for a self-check name
+	status := health.NewStatus(health.ConditionDns, health.ConditionDocker, health.ConditionSync)
+	dnsResolver.Health = status
+	dnsResolver.SelfCheckName = os.Getenv("SELF_CHECK_NAME")
+	dnsResolver.Domain = localDomain
+
+	// keep recent queries for the api and optionally log all of them as json lines
+	queryLogSize, err := strconv.Atoi(getopt("QUERY_LOG_SIZE", "100"))
+	if err != nil || queryLogSize < 0 {
+		return fmt.Errorf("invalid QUERY_LOG_SIZE: %s", os.Getenv("QUERY_LOG_SIZE"))
+	}
+	var queryLogOutput io.Writer
+	switch path := os.Getenv("QUERY_LOG"); path {
+	case "":
+	case "stdout":
+		queryLogOutput = os.Stdout
+	default:
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		queryLogOutput = file
+	}
+	if queryLogSize > 0 || queryLogOutput != nil {
+		dnsResolver.QueryLog = resolver.NewQueryLog(queryLogSize, queryLogOutput)
+	}
+
+	// integrate into the host's resolver configuration
+	backends, err := hostIntegration.NewBackends(getopt("DNS_INTEGRATION", "dnsmasq"))
+	if err != nil {
+		return err
+	}
+	integration := &integration{
+		docker:   docker,
+		backends: backends,
+		resolver: dnsResolver,
+		address:  os.Getenv("DNS_ADDRESS"),
+		domain:   localDomain,
+		logger:   logger.With("component", "integration"),
+	}
+	if err := integration.update(); err != nil {
+		logger.Error("could not write dns integration", "err", err)
+	}
+	defer integration.remove()
+
+	// maintain a hosts file for environments without dns integration
+	if path := os.Getenv("HOSTS_FILE"); path != "" {
+		hosts := &hostsFile.Writer{
+			Path:    path,
+			Managed: getopt("HOSTS_FILE_MODE", "file") == "block",
+			Storage: storage,
+			Logger:  logger.With("component", "hostsFile"),
+		}
+		defer func() {
+			if err := hosts.Remove(); err != nil {
+				logger.Error("could not clean up hosts file", "path", path, "err", err)
+			}
+		}()
+		start(func() error {
+			return hosts.Run(ctx)
+		})
+	}
+
+	// optionally discover the services and tasks of a swarm, on a manager node
+	swarmEnabled, err := strconv.ParseBool(getopt("SWARM_DISCOVERY", "false"))
+	if err != nil {
+		return fmt.Errorf("invalid SWARM_DISCOVERY: %s", os.Getenv("SWARM_DISCOVERY"))
+	}
+	if swarmEnabled {
+		interval, err := time.ParseDuration(getopt("SWARM_SYNC_INTERVAL", "30s"))
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid SWARM_SYNC_INTERVAL: %s", os.Getenv("SWARM_SYNC_INTERVAL"))
+		}
+		discovery := &swarmDiscovery{
+			docker:   docker,
+			storage:  storage,
+			filters:  reloader.filters,
+			zone:     localDomain,
+			interval: interval,
+			logger:   logger.With("component", "swarm"),
+		}
+		start(func() error {
+			return discovery.watch(ctx)
+		})
+	} else {
+		storage.PruneRestored(dnsStorage.SourceSwarm)
 	}
 
 	// start http server
 	env := &httpServer.Environment{
-		Storage: storage,
+		Storage:  storage,
+		Resolver: dnsResolver,
+		Logger:   logger,
+		Health:   status,
+		Reload:   reloader.reload,
 	}
-	httpServer.Run("", 80, env)
+	server := httpServer.NewServer("", 80, env)
+	go func() {
+		if err := server.Run(); err != nil {
+			fail(err)
+		}
+	}()
 
 	go func() {
 		dnsResolver.Wait()
-		exitReason <- errors.New("dns resolver exited")
+		status.Set(health.ConditionDns, false)
+		fail(errors.New("dns resolver exited"))
 	}()
+	start(func() error {
+		return integration.watch(ctx)
+	})
+	start(func() error {
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-hangup:
+				logger.Info("reload requested by signal")
+				reloader.reload()
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	start(func() error {
+		return registerContainers(ctx, docker, nil, dnsResolver, storage, localDomain, reloader.names, reloader.filters, hostIP, logger.With("component", "docker"), status)
+	})
+
+	<-ctx.Done()
+	select {
+	case err = <-exitReason:
+	default:
+		logger.Info("exit requested by signal")
+	}
+
+	shutdown(logger, shutdownTimeout, server, dnsResolver, &workers, storage)
+	return err
+}
+
+// shutdown stops serving http and dns, waits for the subsystems to finish
+// and stops the storage afterwards, giving up after timeout
+func shutdown(
+	logger *slog.Logger,
+	timeout time.Duration,
+	server *httpServer.Server,
+	dnsResolver *resolver.DnsResolver,
+	workers *sync.WaitGroup,
+	storage *dnsStorage.DnsStorage,
+) {
+	logger.Info("shutting down", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Warn("could not shut down http server", "err", err)
+	}
+	if err := dnsResolver.Shutdown(ctx); err != nil {
+		logger.Warn("could not shut down dns resolver", "err", err)
+	}
+
+	workersDone := make(chan struct{})
 	go func() {
-		exitReason <- registerContainers(docker, nil, dnsResolver, storage, localDomain, hostIP)
+		workers.Wait()
+		close(workersDone)
 	}()
-
-	return <-exitReason
+	select {
+	case <-workersDone:
+		storage.Close()
+	case <-ctx.Done():
+		logger.Warn("timeout waiting for subsystems to stop")
+	}
 }
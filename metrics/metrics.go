@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/koestler/dnsdock/dnsStorage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dnsdock"
+
+var (
+	DnsQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dns_queries_total",
+		Help:      "Number of dns queries answered, by query type and response code.",
+	}, []string{"qtype", "rcode"})
+
+	DnsQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "dns_query_duration_seconds",
+		Help:      "Time spent answering dns queries, by query type.",
+		Buckets:   prometheus.ExponentialBuckets(0.00001, 4, 8),
+	}, []string{"qtype"})
+
+	DockerEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "docker_events_total",
+		Help:      "Number of docker events received, by type and action.",
+	}, []string{"type", "action"})
+
+	ContainerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "container_errors_total",
+		Help:      "Number of errors while adding, updating or removing containers, by operation.",
+	}, []string{"operation"})
+)
+
+func init() {
+	prometheus.MustRegister(DnsQueries, DnsQueryDuration, DockerEvents, ContainerErrors)
+}
+
+// RegisterStorage exposes the state of the storage.
+func RegisterStorage(storage *dnsStorage.DnsStorage) {
+	prometheus.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "hosts",
+			Help:      "Number of hosts in the storage.",
+		}, func() float64 {
+			return float64(storage.HostCount())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "subscribers",
+			Help:      "Number of subscribers to storage changes, e.g. websocket and event stream clients.",
+		}, func() float64 {
+			return float64(storage.SubscriberCount())
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "subscriber_resumes_total",
+			Help:      "Number of subscribers resuming after a previously seen revision.",
+		}, func() float64 {
+			return float64(storage.ResumeCount())
+		}),
+	)
+}
+
+// Handler serves the metrics in the prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/koestler/dnsdock/dnsStorage"
+)
+
+func TestHandler(t *testing.T) {
+	RegisterStorage(dnsStorage.NewDnsStorage(slog.Default()))
+	DnsQueries.WithLabelValues("A", "NOERROR").Inc()
+	DockerEvents.WithLabelValues("container", "start").Inc()
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := w.Body.String()
+	for _, name := range []string{
+		`dnsdock_dns_queries_total{qtype="A",rcode="NOERROR"} 1`,
+		"dnsdock_hosts 0",
+		"dnsdock_subscribers 0",
+		`dnsdock_docker_events_total{action="start",type="container"} 1`,
+		"dnsdock_subscriber_resumes_total 0",
+	} {
+		if !strings.Contains(body, name) {
+			t.Errorf("expected %q in metrics output", name)
+		}
+	}
+}
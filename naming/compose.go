@@ -0,0 +1,33 @@
+package naming
+
+import (
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// Compose names containers by their docker compose labels independent of the
+// separator used in the container name, e.g. the first container of the
+// service web of the project shop is named web.shop.docker with the aliases
+// 1.web.shop.docker and shop.docker. The service name resolves to all
+// containers of a scaled service and the project name to all containers of the
+// project. Containers not created by compose are named by the Legacy strategy.
+type Compose struct {
+	Zone string
+}
+
+func (c Compose) Names(container *docker.Container, network string) (name string, aliases []string, err error) {
+	project := label(container, LabelProject)
+	service := label(container, LabelService)
+	if project == "" || service == "" {
+		return Legacy{Zone: c.Zone}.Names(container, network)
+	}
+
+	projectName := strings.ToLower(project + "." + c.Zone)
+	name = strings.ToLower(service) + "." + projectName
+	if number := label(container, LabelNumber); number != "" {
+		aliases = append(aliases, strings.ToLower(number)+"."+name)
+	}
+	aliases = append(aliases, projectName)
+	return
+}
@@ -0,0 +1,13 @@
+package naming
+
+import "testing"
+
+func TestCompose(t *testing.T) {
+	testNames(t, Compose{Zone: "docker"}, []namesTest{
+		{newContainer("shop-web-1", composeLabels("shop", "web", "1")), "shop_default", "web.shop.docker", []string{"1.web.shop.docker", "shop.docker"}},
+		{newContainer("shop_web_2", composeLabels("shop", "web", "2")), "shop_backend", "web.shop.docker", []string{"2.web.shop.docker", "shop.docker"}},
+		{newContainer("custom", composeLabels("Shop", "Web", "")), "shop_default", "web.shop.docker", []string{"shop.docker"}},
+		// not created by compose
+		{newContainer("web", nil), "bridge", "web.docker", nil},
+	})
+}
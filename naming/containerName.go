@@ -0,0 +1,16 @@
+package naming
+
+import (
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// ContainerName names containers by their name only, e.g. web.docker
+type ContainerName struct {
+	Zone string
+}
+
+func (c ContainerName) Names(container *docker.Container, network string) (name string, aliases []string, err error) {
+	return strings.ToLower(containerName(container) + "." + c.Zone), nil, nil
+}
@@ -0,0 +1,11 @@
+package naming
+
+import "testing"
+
+func TestContainerName(t *testing.T) {
+	testNames(t, ContainerName{Zone: "docker"}, []namesTest{
+		{newContainer("web", nil), "bridge", "web.docker", nil},
+		{newContainer("shop-web-1", composeLabels("shop", "web", "1")), "shop_default", "shop-web-1.docker", nil},
+		{newContainer("Web", nil), "backend", "web.docker", nil},
+	})
+}
@@ -0,0 +1,64 @@
+package naming
+
+import (
+	"regexp"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+var hexSlug = regexp.MustCompile("^[0-9a-f]{2,}$")
+
+// Legacy builds names by concatenating the network and the container name,
+// splitting them on _ and joining the parts in reverse order, e.g. the
+// container dcproject_web_1 on the network dcproject_default is named
+// web.dcproject.docker
+type Legacy struct {
+	Zone string
+}
+
+func (l Legacy) Names(container *docker.Container, network string) (name string, aliases []string, err error) {
+	// build an unique container name by concatenating the network and the container name
+	containerNetName := network + "_" + strings.Trim(container.Name, "/_")
+
+	// explode this unique string by _, reverse order and
+	// implode using . (dcprojet_somenet -> somenet.dcproject)
+	// during this:
+	// - ignore "default" and "bridge" as part of the domain
+	// - skip duplicate string a.a.b -> a.b
+	domainParts := []string{}
+	var lastP string
+	for _, p := range strings.Split(containerNetName, "_") {
+		if p == "default" || p == "bridge" || p == lastP {
+			continue
+		}
+
+		domainParts = append([]string{p}, domainParts...)
+		lastP = p
+	}
+
+	// add the zone at the end
+	domainParts = append(domainParts, l.Zone)
+	name = strings.Join(domainParts, ".")
+
+	// docker-compose uses the following naming scheme:
+	// v1.13.0 : <project>_<service>_<index>_<slug> (-> case A)
+	// before  : <project>_<service>_<index>        (-> case B)
+
+	// case B: remove only index
+	// if this succeeds, use the version w/o 1. as domain an register the one with 1. as alias
+	if len(domainParts) > 0 && domainParts[0] == "1" {
+		aliases = append(aliases, name)
+		name = strings.Join(domainParts[1:], ".")
+	}
+
+	// case A: remove slug and index
+	// if this succeeds, use the version w/o slug/index. as domain an register the one with index and slug as alias
+	if len(domainParts) > 1 && domainParts[1] == "1" && hexSlug.MatchString(domainParts[0]) {
+		aliases = append(aliases, name)
+		aliases = append(aliases, strings.Join(domainParts[1:], "."))
+		name = strings.Join(domainParts[2:], ".")
+	}
+
+	return
+}
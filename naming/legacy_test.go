@@ -0,0 +1,19 @@
+package naming
+
+import "testing"
+
+func TestLegacy(t *testing.T) {
+	testNames(t, Legacy{Zone: "docker"}, []namesTest{
+		{newContainer("web", nil), "bridge", "web.docker", nil},
+		{newContainer("web", nil), "backend", "web.backend.docker", nil},
+		{newContainer("shop_web_1", nil), "shop_default", "web.shop.docker", []string{"1.web.shop.docker"}},
+		{newContainer("shop_web_1_0a1b2c3d", nil), "shop_default", "web.shop.docker", []string{
+			"0a1b2c3d.1.web.shop.docker",
+			"1.web.shop.docker",
+		}},
+		// only consecutive duplicates are skipped, the index is kept unless it is 1
+		{newContainer("shop_web_2", nil), "shop_backend", "2.web.shop.backend.shop.docker", nil},
+		// compose v2 separates with - which is kept as is
+		{newContainer("shop-web-1", nil), "shop_default", "shop-web-1.shop.docker", nil},
+	})
+}
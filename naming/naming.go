@@ -0,0 +1,82 @@
+package naming
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// labels set by docker compose
+const (
+	LabelProject = "com.docker.compose.project"
+	LabelService = "com.docker.compose.service"
+	LabelNumber  = "com.docker.compose.container-number"
+)
+
+// NamingStrategy derives the hostname and aliases of a container on one of its networks
+type NamingStrategy interface {
+	Names(container *docker.Container, network string) (name string, aliases []string, err error)
+}
+
+// Config selects the strategy, Strategy is one of compose (the default),
+// legacy, name or template. Template is only used by the template strategy.
+type Config struct {
+	Strategy string
+	Template string
+}
+
+// New creates the strategy selected by config for names within zone, e.g. docker
+func New(config Config, zone string) (NamingStrategy, error) {
+	zone = strings.Trim(zone, ".")
+
+	switch config.Strategy {
+	case "", "compose":
+		return Compose{Zone: zone}, nil
+	case "legacy":
+		return Legacy{Zone: zone}, nil
+	case "name":
+		return ContainerName{Zone: zone}, nil
+	case "template":
+		return NewTemplate(config.Template, zone)
+	}
+	return nil, fmt.Errorf("unknown naming strategy %q", config.Strategy)
+}
+
+// containerName returns the name of the container without the leading slash
+func containerName(container *docker.Container) string {
+	return strings.Trim(container.Name, "/")
+}
+
+func label(container *docker.Container, key string) string {
+	if container.Config == nil {
+		return ""
+	}
+	return container.Config.Labels[key]
+}
+
+// Selector holds the strategy in use, it can be replaced when the
+// configuration is reloaded
+type Selector struct {
+	mutex    sync.RWMutex
+	strategy NamingStrategy
+}
+
+func NewSelector(strategy NamingStrategy) *Selector {
+	return &Selector{strategy: strategy}
+}
+
+func (s *Selector) Set(strategy NamingStrategy) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.strategy = strategy
+}
+
+func (s *Selector) Get() NamingStrategy {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.strategy
+}
@@ -0,0 +1,80 @@
+package naming
+
+import (
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+func newContainer(name string, labels map[string]string) *docker.Container {
+	return &docker.Container{
+		ID:     "0123456789abcdef0123456789abcdef",
+		Name:   "/" + name,
+		Config: &docker.Config{Image: "nginx:latest", Labels: labels},
+	}
+}
+
+func composeLabels(project, service, number string) map[string]string {
+	return map[string]string{
+		LabelProject: project,
+		LabelService: service,
+		LabelNumber:  number,
+	}
+}
+
+type namesTest struct {
+	container *docker.Container
+	network   string
+	name      string
+	aliases   []string
+}
+
+func testNames(t *testing.T, strategy NamingStrategy, tests []namesTest) {
+	t.Helper()
+	for _, test := range tests {
+		name, aliases, err := strategy.Names(test.container, test.network)
+		if err != nil {
+			t.Errorf("%s on %s: unexpected error %v", test.container.Name, test.network, err)
+			continue
+		}
+		if name != test.name {
+			t.Errorf("%s on %s: expected name %q, got %q", test.container.Name, test.network, test.name, name)
+		}
+		if len(aliases) != len(test.aliases) {
+			t.Errorf("%s on %s: expected aliases %v, got %v", test.container.Name, test.network, test.aliases, aliases)
+			continue
+		}
+		for i := range aliases {
+			if aliases[i] != test.aliases[i] {
+				t.Errorf("%s on %s: expected aliases %v, got %v", test.container.Name, test.network, test.aliases, aliases)
+				break
+			}
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		config Config
+		valid  bool
+	}{
+		{Config{}, true},
+		{Config{Strategy: "legacy"}, true},
+		{Config{Strategy: "compose"}, true},
+		{Config{Strategy: "name"}, true},
+		{Config{Strategy: "template", Template: "{{.Name}}.{{.Zone}}"}, true},
+		{Config{Strategy: "template"}, false},
+		{Config{Strategy: "template", Template: "{{.Name"}, false},
+		{Config{Strategy: "unknown"}, false},
+	}
+
+	for _, test := range tests {
+		strategy, err := New(test.config, "docker")
+		if test.valid && (err != nil || strategy == nil) {
+			t.Errorf("%+v: unexpected error %v", test.config, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%+v: expected an error", test.config)
+		}
+	}
+}
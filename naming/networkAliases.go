@@ -0,0 +1,26 @@
+package naming
+
+import (
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// NetworkAliases returns the aliases of the container on the network, as set
+// by --network-alias or the aliases of a compose service, as names within zone.
+// The alias of the short container id added by docker is skipped.
+func NetworkAliases(container *docker.Container, network string, zone string) (aliases []string) {
+	if container.NetworkSettings == nil {
+		return
+	}
+	zone = strings.Trim(zone, ".")
+
+	for _, alias := range container.NetworkSettings.Networks[network].Aliases {
+		alias = strings.ToLower(strings.Trim(alias, "."))
+		if alias == "" || (len(alias) >= 12 && strings.HasPrefix(container.ID, alias)) {
+			continue
+		}
+		aliases = append(aliases, alias+"."+zone)
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package naming
+
+import (
+	"reflect"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+func TestNetworkAliases(t *testing.T) {
+	container := newContainer("shop-web-1", nil)
+	container.NetworkSettings = &docker.NetworkSettings{
+		Networks: map[string]docker.ContainerNetwork{
+			"shop_default": {Aliases: []string{"shop-web-1", "web", "0123456789ab"}},
+			"backend":      {Aliases: []string{"API", "api.internal"}},
+			"bridge":       {},
+		},
+	}
+
+	tests := []struct {
+		network string
+		aliases []string
+	}{
+		{"shop_default", []string{"shop-web-1.docker", "web.docker"}},
+		{"backend", []string{"api.docker", "api.internal.docker"}},
+		{"bridge", nil},
+		{"unknown", nil},
+	}
+
+	for _, test := range tests {
+		if aliases := NetworkAliases(container, test.network, ".docker"); !reflect.DeepEqual(aliases, test.aliases) {
+			t.Errorf("%s: expected %v, got %v", test.network, test.aliases, aliases)
+		}
+	}
+}
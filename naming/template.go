@@ -0,0 +1,79 @@
+package naming
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"text/template"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// TemplateData is available to naming templates
+type TemplateData struct {
+	// Name is the container name without the leading slash
+	Name string
+	// Id is the short container id
+	Id      string
+	Image   string
+	Network string
+	Zone    string
+	// Project, Service and Number are taken from the docker compose labels
+	Project string
+	Service string
+	Number  string
+	Labels  map[string]string
+}
+
+// Template names containers using a text/template, e.g. {{.Service}}.{{.Project}}.{{.Zone}}
+type Template struct {
+	Zone     string
+	template *template.Template
+}
+
+func NewTemplate(text string, zone string) (*Template, error) {
+	if text == "" {
+		return nil, errors.New("empty naming template")
+	}
+	t, err := template.New("naming").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{Zone: zone, template: t}, nil
+}
+
+func (t *Template) Names(container *docker.Container, network string) (name string, aliases []string, err error) {
+	data := TemplateData{
+		Name:    containerName(container),
+		Id:      container.ID,
+		Network: network,
+		Zone:    t.Zone,
+		Project: label(container, LabelProject),
+		Service: label(container, LabelService),
+		Number:  label(container, LabelNumber),
+	}
+	if len(data.Id) > 12 {
+		data.Id = data.Id[:12]
+	}
+	if container.Config != nil {
+		data.Image = container.Config.Image
+		data.Labels = container.Config.Labels
+	}
+
+	var b bytes.Buffer
+	if err = t.template.Execute(&b, data); err != nil {
+		return "", nil, err
+	}
+
+	// empty parts, e.g. of missing labels, are dropped
+	var parts []string
+	for _, part := range strings.Split(strings.ToLower(strings.TrimSpace(b.String())), ".") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "", nil, errors.New("naming template resulted in an empty name")
+	}
+	return strings.Join(parts, "."), nil, nil
+}
@@ -0,0 +1,31 @@
+package naming
+
+import "testing"
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		name     string
+	}{
+		{"{{.Service}}.{{.Project}}.{{.Zone}}", "web.shop.docker"},
+		{"{{.Name}}.{{.Network}}.{{.Zone}}", "shop-web-1.shop_default.docker"},
+		{"{{.Id}}.{{.Zone}}", "0123456789ab.docker"},
+		{`{{index .Labels "com.docker.compose.service"}}.{{.Zone}}`, "web.docker"},
+		// parts of missing labels are dropped
+		{`{{.Service}}.{{index .Labels "missing"}}.{{.Zone}}`, "web.docker"},
+	}
+
+	container := newContainer("shop-web-1", composeLabels("shop", "web", "1"))
+	for _, test := range tests {
+		strategy, err := NewTemplate(test.template, "docker")
+		if err != nil {
+			t.Fatalf("%s: %v", test.template, err)
+		}
+		testNames(t, strategy, []namesTest{{container, "shop_default", test.name, nil}})
+	}
+
+	strategy, _ := NewTemplate("{{.Service}}", "docker")
+	if _, _, err := strategy.Names(newContainer("web", nil), "bridge"); err == nil {
+		t.Error("expected an error for an empty name")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	dockerapi "github.com/fsouza/go-dockerclient"
+)
+
+// references of --net=container:X followed before giving up
+const maxNamespaceDepth = 10
+
+// network of the addresses of containers with --net=host
+const hostNetwork = "host"
+
+// networkAddresses returns the addresses to register for a container by
+// network. Containers with --net=host get hostIP, if set, and containers with
+// --net=container:X the addresses of X, whose id is returned as owner.
+func networkAddresses(
+	container *dockerapi.Container,
+	inspect func(id string) (*dockerapi.Container, error),
+	hostIP net.IP,
+) (addresses map[string]net.IP, owner string, err error) {
+	for depth := 0; ; depth++ {
+		var mode string
+		if container.HostConfig != nil {
+			mode = container.HostConfig.NetworkMode
+		}
+
+		target, shared := strings.CutPrefix(mode, "container:")
+		if !shared {
+			break
+		}
+		if depth == maxNamespaceDepth {
+			return nil, "", fmt.Errorf("network namespace of %s nested too deep", container.Name)
+		}
+		if container, err = inspect(target); err != nil {
+			return nil, "", err
+		}
+		owner = container.ID
+	}
+
+	addresses = make(map[string]net.IP)
+	if container.HostConfig != nil && container.HostConfig.NetworkMode == hostNetwork {
+		if hostIP != nil {
+			addresses[hostNetwork] = hostIP
+		}
+		return
+	}
+	if container.NetworkSettings == nil {
+		return
+	}
+	for netId, network := range container.NetworkSettings.Networks {
+		// e.g. the none network has no address to register
+		if addr := net.ParseIP(network.IPAddress); addr != nil {
+			addresses[netId] = addr
+		}
+	}
+	return
+}
+
+// namespaces tracks the containers sharing the network namespace of another
+// one, to update them when the addresses of the owner change
+type namespaces struct {
+	mutex      sync.Mutex
+	dependents map[string]map[string]bool
+}
+
+func newNamespaces() *namespaces {
+	return &namespaces{dependents: make(map[string]map[string]bool)}
+}
+
+func (n *namespaces) add(owner, containerId string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.dependents[owner] == nil {
+		n.dependents[owner] = make(map[string]bool)
+	}
+	n.dependents[owner][containerId] = true
+}
+
+func (n *namespaces) remove(containerId string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	for owner, dependents := range n.dependents {
+		delete(dependents, containerId)
+		if len(dependents) == 0 {
+			delete(n.dependents, owner)
+		}
+	}
+}
+
+func (n *namespaces) dependentsOf(owner string) (containerIds []string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	for containerId := range n.dependents[owner] {
+		containerIds = append(containerIds, containerId)
+	}
+	return
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	dockerapi "github.com/fsouza/go-dockerclient"
+)
+
+func TestNetworkAddresses(t *testing.T) {
+	hostIP := net.ParseIP("192.168.42.42")
+
+	web := &dockerapi.Container{
+		ID:         "5f2bd1c9e5a0",
+		Name:       "/web",
+		HostConfig: &dockerapi.HostConfig{NetworkMode: "bridge"},
+		NetworkSettings: &dockerapi.NetworkSettings{Networks: map[string]dockerapi.ContainerNetwork{
+			"bridge": {IPAddress: "172.17.0.2"},
+			"none":   {},
+		}},
+	}
+	sidecar := &dockerapi.Container{
+		ID:         "8c1e0a5b7d3f",
+		Name:       "/sidecar",
+		HostConfig: &dockerapi.HostConfig{NetworkMode: "container:web"},
+	}
+	agent := &dockerapi.Container{
+		ID:         "a3b9d2e6f1c4",
+		Name:       "/agent",
+		HostConfig: &dockerapi.HostConfig{NetworkMode: "host"},
+		NetworkSettings: &dockerapi.NetworkSettings{Networks: map[string]dockerapi.ContainerNetwork{
+			"host": {},
+		}},
+	}
+	loop := &dockerapi.Container{
+		Name:       "/loop",
+		HostConfig: &dockerapi.HostConfig{NetworkMode: "container:loop"},
+	}
+
+	containers := map[string]*dockerapi.Container{
+		"web":     web,
+		"sidecar": sidecar,
+		"loop":    loop,
+	}
+	inspect := func(id string) (*dockerapi.Container, error) {
+		if container, ok := containers[id]; ok {
+			return container, nil
+		}
+		return nil, errors.New("no such container: " + id)
+	}
+
+	tests := []struct {
+		container *dockerapi.Container
+		hostIP    net.IP
+		addresses map[string]string
+		owner     string
+	}{
+		{web, nil, map[string]string{"bridge": "172.17.0.2"}, ""},
+		{agent, nil, map[string]string{}, ""},
+		{agent, hostIP, map[string]string{"host": "192.168.42.42"}, ""},
+		{sidecar, nil, map[string]string{"bridge": "172.17.0.2"}, web.ID},
+		// nested reference
+		{&dockerapi.Container{HostConfig: &dockerapi.HostConfig{NetworkMode: "container:sidecar"}}, nil, map[string]string{"bridge": "172.17.0.2"}, web.ID},
+	}
+
+	for _, test := range tests {
+		addresses, owner, err := networkAddresses(test.container, inspect, test.hostIP)
+		if err != nil {
+			t.Fatalf("%s: %v", test.container.Name, err)
+		}
+		if owner != test.owner {
+			t.Errorf("%s: expected owner %q, got %q", test.container.Name, test.owner, owner)
+		}
+		if len(addresses) != len(test.addresses) {
+			t.Errorf("%s: expected %v, got %v", test.container.Name, test.addresses, addresses)
+		}
+		for network, address := range test.addresses {
+			if addresses[network].String() != address {
+				t.Errorf("%s: expected %s on %s, got %v", test.container.Name, address, network, addresses[network])
+			}
+		}
+	}
+
+	if _, _, err := networkAddresses(loop, inspect, nil); err == nil {
+		t.Error("expected an error for a reference loop")
+	}
+	if _, _, err := networkAddresses(&dockerapi.Container{HostConfig: &dockerapi.HostConfig{NetworkMode: "container:gone"}}, inspect, nil); err == nil {
+		t.Error("expected an error for a missing container")
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	n := newNamespaces()
+	n.add("web", "sidecar")
+	n.add("web", "exporter")
+
+	if dependents := n.dependentsOf("web"); len(dependents) != 2 {
+		t.Fatalf("expected 2 dependents, got %v", dependents)
+	}
+
+	// the owner keeps its dependents until they are removed themselves
+	n.remove("web")
+	n.remove("sidecar")
+	if dependents := n.dependentsOf("web"); len(dependents) != 1 || dependents[0] != "exporter" {
+		t.Errorf("expected exporter only, got %v", dependents)
+	}
+
+	n.remove("exporter")
+	if len(n.dependents) != 0 {
+		t.Errorf("expected no dependents, got %v", n.dependents)
+	}
+}
@@ -1,184 +1,283 @@
 package main
 
 import (
+	"context"
 	"errors"
 	dockerapi "github.com/fsouza/go-dockerclient"
+	"github.com/koestler/dnsdock/containerFilter"
 	"github.com/koestler/dnsdock/dnsStorage"
+	"github.com/koestler/dnsdock/health"
+	"github.com/koestler/dnsdock/metrics"
+	"github.com/koestler/dnsdock/naming"
 	"github.com/koestler/dnsdock/resolver"
-	"log"
+	"log/slog"
 	"net"
-	"regexp"
+	"slices"
 	"strings"
 )
 
 func registerContainers(
+	ctx context.Context,
 	docker *dockerapi.Client,
 	events chan *dockerapi.APIEvents,
 	dns resolver.Resolver,
 	storage *dnsStorage.DnsStorage,
 	containerDomain string,
+	names *naming.Selector,
+	filters *containerFilter.Selector,
 	hostIP net.IP,
+	logger *slog.Logger,
+	status *health.Status,
 ) error {
 	// TODO add an options struct instead of passing all as parameters
 	// though passing the events channel from an options struct was triggering
 	// data race warnings within AddEventListener, so needs more investigation
 
 	if events == nil {
-		events = make(chan *dockerapi.APIEvents)
+		events = make(chan *dockerapi.APIEvents, dockerEventBuffer)
 	}
 	if err := docker.AddEventListener(events); err != nil {
 		return err
 	}
+	status.Set(health.ConditionDocker, true)
+	defer status.Set(health.ConditionDocker, false)
 
 	if !strings.HasPrefix(containerDomain, ".") {
 		containerDomain = "." + containerDomain
 	}
 
-	addContainer := func(containerId string) error {
-		container, err := docker.InspectContainer(containerId)
-		if err != nil {
+	registry := &containerRegistry{
+		inspect:    docker.InspectContainer,
+		storage:    storage,
+		domain:     containerDomain,
+		names:      names,
+		filters:    filters,
+		hostIP:     hostIP,
+		logger:     logger,
+		shared:     newNamespaces(),
+		registered: make(map[string]map[string]bool),
+	}
+
+	// a storage restored from a snapshot can answer queries while the
+	// containers are re-synced, otherwise only start listening afterwards
+	listening := false
+	if storage.IsPersistent() {
+		if err := dns.Listen(); err != nil {
 			return err
 		}
+		status.Set(health.ConditionDns, true)
+		listening = true
+	}
 
-		log.Printf("add container (name=%v, id=%v)", container.Name, containerId)
+	containers, err := docker.ListContainers(dockerapi.ListContainersOptions{})
+	if err != nil {
+		return err
+	}
 
-		first := true
+	// add existing containers
+	for _, listing := range containers {
+		if err := registry.add(listing.ID); err != nil {
+			metrics.ContainerErrors.WithLabelValues("add").Inc()
+			logger.Error("could not add container", "id", listing.ID[:12], "err", err)
+		}
+	}
 
-		// register a hostname for each network of this container
-		for netId, network := range container.NetworkSettings.Networks {
-			log.Printf("  found network (name=%v)", netId)
+	// drop containers restored from a snapshot which are gone by now
+	storage.PruneRestored(dnsStorage.SourceContainer)
+	status.Set(health.ConditionSync, true)
 
-			// build an unique container name by concatenating the network and the container name
-			containerNetName := netId + "_" + strings.Trim(container.Name, "/_")
+	if !listening {
+		if err = dns.Listen(); err != nil {
+			return err
+		}
+		status.Set(health.ConditionDns, true)
+	}
 
-			// explode this unique string by _, reverse order, append docker and
-			// implode using . (dcprojet_somenet -> somenet.dcproject.docker)
-			// during this:
-			// - ignore "default" and "bridge" as part of the domain
-			// - skip duplicate string a.a.b -> a.b
-			domainParts := []string{}
-			var lastP string
-			for _, p := range strings.Split(containerNetName, "_") {
-				// - ignore "default" as part of the domain
-				if strings.Compare(p, "default") == 0 {
-					continue
-				}
+	// handle docker api events in order, e.g. a die before the start of a
+	// restarted container
+	for {
+		var msg *dockerapi.APIEvents
+		select {
+		case msg = <-events:
+		case <-ctx.Done():
+			docker.RemoveEventListener(events)
+			return nil
+		}
+		if msg == nil {
+			return errors.New("docker event loop closed")
+		}
 
-				// - ignore "bridge" as part of the domain
-				if strings.Compare(p, "bridge") == 0 {
-					continue
-				}
+		// actions of exec and health events carry details after a colon,
+		// e.g. "health_status: healthy", which are left out of the metrics
+		action, _, _ := strings.Cut(msg.Action, ":")
+		metrics.DockerEvents.WithLabelValues(msg.Type, action).Inc()
 
-				// - skip duplicate string a.a.b -> a.b
-				if strings.Compare(p, lastP) == 0 {
-					continue
-				}
+		switch msg.Status {
+		case "start":
+			if err := registry.add(msg.ID); err != nil {
+				metrics.ContainerErrors.WithLabelValues("add").Inc()
+				logger.Error("could not add container", "id", msg.ID[:12], "err", err)
+			}
+		case "die":
+			if err := registry.remove(msg.ID); err != nil {
+				metrics.ContainerErrors.WithLabelValues("remove").Inc()
+				logger.Error("could not remove container", "id", msg.ID[:12], "err", err)
+			}
+		}
 
-				domainParts = append([]string{p}, domainParts...)
-				lastP = p
+		// addresses change when a running container is connected to
+		// or disconnected from a network
+		if msg.Type == "network" && (msg.Action == "connect" || msg.Action == "disconnect") {
+			if containerId := msg.Actor.Attributes["container"]; containerId != "" {
+				if err := registry.add(containerId); err != nil {
+					metrics.ContainerErrors.WithLabelValues("update").Inc()
+					logger.Error("could not update container", "id", containerId[:12], "err", err)
+				}
 			}
+		}
+	}
+}
 
-			// add .docker at the end
-			domainParts = append(domainParts, "docker")
-			domain := strings.Join(domainParts, ".")
+// containerRegistry registers the hosts of containers in the storage, it is
+// only used by the docker event loop
+type containerRegistry struct {
+	inspect func(id string) (*dockerapi.Container, error)
+	storage *dnsStorage.DnsStorage
+	// domain of the container names, with a leading dot
+	domain  string
+	names   *naming.Selector
+	filters *containerFilter.Selector
+	hostIP  net.IP
+	logger  *slog.Logger
+	// containers sharing the network namespace of another one
+	shared *namespaces
+	// ids of the hosts added by container id
+	registered map[string]map[string]bool
+}
 
-			// generate aliases
-			aliases := make([]string, 0, 5)
+// removeHosts removes the hosts added for a container except the ones in keep.
+// Hosts restored from a snapshot are left to PruneRestored.
+func (r *containerRegistry) removeHosts(containerId string, keep map[string]bool) {
+	for id := range r.registered[containerId] {
+		if !keep[id] {
+			r.storage.RemoveHost(id)
+		}
+	}
 
-			// docker-compose uses the following naming scheme:
-			// v1.13.0 : <project>_<service>_<index>_<slug> (-> case A)
-			// before  : <project>_<service>_<index>        (-> case B)
+	if len(keep) == 0 {
+		delete(r.registered, containerId)
+	} else {
+		r.registered[containerId] = keep
+	}
+}
 
-			// case B: remove only index
-			// if this succeeds, use the version w/o 1. as domain an register the one with 1. as alias
-			if len(domainParts) > 0 && strings.Compare(domainParts[0], "1") == 0 {
-				aliases = append(aliases, domain)
-				domain = strings.Join(domainParts[1:], ".")
-			}
+// add registers a hostname for each network of a running container and
+// updates the containers sharing its network namespace
+func (r *containerRegistry) add(containerId string) error {
+	container, err := r.inspect(containerId)
+	if err != nil {
+		return err
+	}
 
-			// case A: remove slug and index
-			// if this succeeds, use the version w/o slug/index. as domain an register the one with index and slug as alias
-			if rHex, _ := regexp.Compile("^[0-9a-f]{2,}$"); len(domainParts) > 0 &&
-				strings.Compare(domainParts[1], "1") == 0 &&
-				rHex.Match([]byte(domainParts[0])) {
+	filter := r.filters.Get()
+	if !filter.Container(container) {
+		// the container may have been registered before a reload
+		r.logger.Debug("skip filtered container", "name", container.Name, "id", containerId)
+		r.removeHosts(containerId, nil)
+		return nil
+	}
+	if !container.State.Running {
+		// e.g. a container sharing the network namespace of a restarted one
+		r.shared.remove(containerId)
+		r.removeHosts(containerId, nil)
+		return nil
+	}
 
-				aliases = append(aliases, domain)
-				aliases = append(aliases, strings.Join(domainParts[1:], "."))
-				domain = strings.Join(domainParts[2:], ".")
-			}
+	addresses, owner, err := networkAddresses(container, r.inspect, r.hostIP)
+	if err != nil {
+		return err
+	}
+	if owner != "" {
+		r.shared.add(owner, containerId)
+	}
 
-			// for first network only: generate alias by the first 12 characters of the containerId
-			if first {
-				aliases = append(aliases, containerId[:12]+".docker")
-				first = false
-			}
+	r.logger.Info("add container", "name", container.Name, "id", containerId)
 
-			log.Printf("  --> add records (ip='%v', domain='%v', aliases=%v)", network.IPAddress, domain, aliases)
+	first := true
+	added := make(map[string]bool)
 
-			addr := net.ParseIP(network.IPAddress)
-			storage.AddHost(dnsStorage.Host{
-				Id:               containerId + "_" + netId,
-				Address:          addr,
-				Name:             domain,
-				Aliases:          aliases,
-				Container:        container,
-			})
+	for netId, addr := range addresses {
+		r.logger.Debug("found network", "container", container.Name, "network", netId)
 
-			if err != nil {
-				return err
-			}
+		if !filter.Network(netId) {
+			r.logger.Debug("skip filtered network", "container", container.Name, "network", netId)
+			continue
 		}
 
-		return nil
-	}
-
-	removeContainer := func(containerId string) error {
-		container, err := docker.InspectContainer(containerId)
+		domain, aliases, err := r.names.Get().Names(container, netId)
 		if err != nil {
 			return err
 		}
 
-		for netId, _ := range container.NetworkSettings.Networks {
-			dns.RemoveHost(containerId + "_" + netId)
+		// aliases of the network, e.g. --network-alias
+		for _, alias := range naming.NetworkAliases(container, netId, r.domain) {
+			if alias != domain && !slices.Contains(aliases, alias) {
+				aliases = append(aliases, alias)
+			}
 		}
 
-		log.Printf("remove container (name=%v, id=%v)", container.Name, containerId)
+		// for first network only: generate alias by the first 12 characters of the containerId
+		if first {
+			aliases = append(aliases, containerId[:12]+r.domain)
+			first = false
+		}
 
-		return nil
-	}
+		r.logger.Info("add records", "container", container.Name, "ip", addr, "domain", domain, "aliases", aliases)
 
-	containers, err := docker.ListContainers(dockerapi.ListContainersOptions{})
-	if err != nil {
-		return err
+		// the host's address is reachable from all networks, like the
+		// addresses of static records
+		network := netId
+		if netId == hostNetwork {
+			network = ""
+		}
+
+		id := containerId + "_" + netId
+		r.storage.AddHost(dnsStorage.Host{
+			Id:        id,
+			Address:   addr,
+			Name:      domain,
+			Aliases:   aliases,
+			Container: container,
+			Network:   network,
+			Source:    dnsStorage.SourceContainer,
+		})
+		added[id] = true
 	}
 
-	// add existing containers
-	for _, listing := range containers {
-		if err := addContainer(listing.ID); err != nil {
-			log.Printf("error adding container %s: %s\n", listing.ID[:12], err)
+	// networks the container is no longer connected to or which are filtered
+	r.removeHosts(containerId, added)
+
+	// containers sharing the network namespace get the new addresses
+	for _, dependent := range r.shared.dependentsOf(containerId) {
+		if err := r.add(dependent); err != nil {
+			r.logger.Error("could not update container", "id", dependent[:12], "err", err)
 		}
 	}
 
-	if err = dns.Listen(); err != nil {
-		return err
-	}
-	defer dns.Close()
+	return nil
+}
 
-	// handle docker api events
-	for msg := range events {
-		go func(msg *dockerapi.APIEvents) {
-			switch msg.Status {
-			case "start":
-				if err := addContainer(msg.ID); err != nil {
-					log.Printf("error adding container %s: %s\n", msg.ID[:12], err)
-				}
-			case "die":
-				if err := removeContainer(msg.ID); err != nil {
-					log.Printf("error adding container %s: %s\n", msg.ID[:12], err)
-				}
-			}
-		}(msg)
+// remove removes the hosts of a container and of the containers sharing its
+// network namespace, which is gone with it
+func (r *containerRegistry) remove(containerId string) error {
+	r.removeHosts(containerId, nil)
+	r.shared.remove(containerId)
+
+	for _, dependent := range r.shared.dependentsOf(containerId) {
+		r.removeHosts(dependent, nil)
 	}
 
-	return errors.New("docker event loop closed")
+	r.logger.Info("remove container", "id", containerId)
+
+	return nil
 }
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	dockerapi "github.com/fsouza/go-dockerclient"
+	"github.com/koestler/dnsdock/containerFilter"
+	"github.com/koestler/dnsdock/dnsStorage"
+	"github.com/koestler/dnsdock/naming"
+)
+
+// newTestRegistry returns a registry inspecting the given containers by id
+func newTestRegistry(t *testing.T, containers map[string]*dockerapi.Container) (*containerRegistry, *dnsStorage.Subscription) {
+	storage := dnsStorage.NewDnsStorage(slog.Default())
+	t.Cleanup(storage.Close)
+
+	strategy, err := naming.New(naming.Config{Strategy: "name"}, "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, _ := containerFilter.New(containerFilter.Config{})
+
+	registry := &containerRegistry{
+		inspect: func(id string) (*dockerapi.Container, error) {
+			if container, ok := containers[id]; ok {
+				return container, nil
+			}
+			return nil, &dockerapi.NoSuchContainer{ID: id}
+		},
+		storage:    storage,
+		domain:     ".docker",
+		names:      naming.NewSelector(strategy),
+		filters:    containerFilter.NewSelector(filter),
+		logger:     slog.Default(),
+		shared:     newNamespaces(),
+		registered: make(map[string]map[string]bool),
+	}
+	return registry, storage.Subscribe()
+}
+
+func newTestContainer(id, name, image string, networks map[string]string) *dockerapi.Container {
+	container := &dockerapi.Container{
+		ID:              id,
+		Name:            "/" + name,
+		Config:          &dockerapi.Config{Image: image},
+		State:           dockerapi.State{Running: true},
+		HostConfig:      &dockerapi.HostConfig{NetworkMode: "bridge"},
+		NetworkSettings: &dockerapi.NetworkSettings{Networks: map[string]dockerapi.ContainerNetwork{}},
+	}
+	for network, address := range networks {
+		container.NetworkSettings.Networks[network] = dockerapi.ContainerNetwork{IPAddress: address}
+	}
+	return container
+}
+
+// waitChanges waits for n changes of the storage
+func waitChanges(t *testing.T, subscription *dnsStorage.Subscription, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-subscription.Changes:
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for change %d of %d", i+1, n)
+		}
+	}
+}
+
+// hostIds returns the sorted ids of the hosts in the storage
+func hostIds(r *containerRegistry) (ids []string) {
+	for id := range r.storage.GetHosts() {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return
+}
+
+func setFilter(t *testing.T, r *containerRegistry, config containerFilter.Config) {
+	filter, err := containerFilter.New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.filters.Set(filter)
+}
+
+func TestAddFilteredContainer(t *testing.T) {
+	web := newTestContainer("5f2bd1c9e5a0aa", "web", "nginx", map[string]string{"bridge": "172.17.0.2"})
+	registry, subscription := newTestRegistry(t, map[string]*dockerapi.Container{web.ID: web})
+
+	if err := registry.add(web.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitChanges(t, subscription, 1)
+
+	// e.g. a reload excluding the image followed by a network event
+	setFilter(t, registry, containerFilter.Config{ExcludeImages: []string{"nginx"}})
+	if err := registry.add(web.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitChanges(t, subscription, 1)
+
+	if ids := hostIds(registry); len(ids) != 0 {
+		t.Errorf("expected the filtered container to be removed, got %v", ids)
+	}
+}
+
+func TestAddFilteredNetwork(t *testing.T) {
+	web := newTestContainer("5f2bd1c9e5a0aa", "web", "nginx", map[string]string{
+		"shop_front":    "172.18.0.2",
+		"shop_internal": "172.19.0.2",
+	})
+	registry, subscription := newTestRegistry(t, map[string]*dockerapi.Container{web.ID: web})
+
+	if err := registry.add(web.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitChanges(t, subscription, 2)
+
+	setFilter(t, registry, containerFilter.Config{ExcludeNetworks: []string{"*_internal"}})
+	if err := registry.add(web.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitChanges(t, subscription, 1)
+
+	ids := hostIds(registry)
+	if len(ids) != 1 || !strings.HasSuffix(ids[0], "_shop_front") {
+		t.Errorf("expected only the host on shop_front, got %v", ids)
+	}
+	if addrs := registry.storage.FindHostAddresses("web.docker."); len(addrs) != 1 || !addrs[0].Equal(net.ParseIP("172.18.0.2")) {
+		t.Errorf("expected the address on shop_front, got %v", addrs)
+	}
+}
+
+func TestSharedNetworkNamespace(t *testing.T) {
+	web := newTestContainer("5f2bd1c9e5a0aa", "web", "nginx", map[string]string{"bridge": "172.17.0.2"})
+	sidecar := newTestContainer("8c1e0a5b7d3fbb", "sidecar", "envoy", nil)
+	sidecar.HostConfig.NetworkMode = "container:" + web.ID
+	registry, subscription := newTestRegistry(t, map[string]*dockerapi.Container{web.ID: web, sidecar.ID: sidecar})
+
+	if err := registry.add(web.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.add(sidecar.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitChanges(t, subscription, 2)
+	if addrs := registry.storage.FindHostAddresses("sidecar.docker."); len(addrs) != 1 || !addrs[0].Equal(net.ParseIP("172.17.0.2")) {
+		t.Fatalf("expected the address of web, got %v", addrs)
+	}
+
+	// web is reconnected and gets a new address
+	web.NetworkSettings.Networks["bridge"] = dockerapi.ContainerNetwork{IPAddress: "172.17.0.9"}
+	if err := registry.add(web.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitChanges(t, subscription, 2)
+	for _, name := range []string{"web.docker.", "sidecar.docker."} {
+		if addrs := registry.storage.FindHostAddresses(name); len(addrs) != 1 || !addrs[0].Equal(net.ParseIP("172.17.0.9")) {
+			t.Errorf("%s: expected the new address of web, got %v", name, addrs)
+		}
+	}
+
+	// the namespace is gone with web
+	if err := registry.remove(web.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitChanges(t, subscription, 2)
+	if ids := hostIds(registry); len(ids) != 0 {
+		t.Errorf("expected no hosts, got %v", ids)
+	}
+}
+
+func TestHostNetwork(t *testing.T) {
+	agent := newTestContainer("a3b9d2e6f1c4dd", "agent", "telegraf", map[string]string{"host": ""})
+	agent.HostConfig.NetworkMode = "host"
+	registry, subscription := newTestRegistry(t, map[string]*dockerapi.Container{agent.ID: agent})
+	registry.hostIP = net.ParseIP("192.168.42.42")
+
+	if err := registry.add(agent.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitChanges(t, subscription, 1)
+
+	// reachable from all networks, hence never scoped to one
+	hosts := registry.storage.FindHosts("agent.docker.")
+	if len(hosts) != 1 || !hosts[0].Address.Equal(registry.hostIP) || hosts[0].Network != "" {
+		t.Errorf("expected the host address without a network, got %+v", hosts)
+	}
+}
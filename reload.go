@@ -0,0 +1,151 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/koestler/dnsdock/containerFilter"
+	"github.com/koestler/dnsdock/dnsStorage"
+	"github.com/koestler/dnsdock/httpServer"
+	"github.com/koestler/dnsdock/naming"
+	"github.com/koestler/dnsdock/resolver"
+)
+
+// reloader applies the configuration file at startup and whenever it is
+// reloaded, without touching the dns listeners or the containers
+type reloader struct {
+	path     string
+	storage  *dnsStorage.DnsStorage
+	resolver *resolver.DnsResolver
+	logger   *slog.Logger
+	logLevel *slog.LevelVar
+	// level given by the environment, used if the config does not set one
+	defaultLogLevel slog.Level
+	names           *naming.Selector
+	// naming given by the environment, used if the config does not set one
+	defaultNaming naming.Config
+	zone          string
+	// policy given by the environment, used if the config does not set one
+	defaultConflictPolicy string
+	// scope given by the environment, used if the config does not set one
+	defaultNetworkScope string
+	filters             *containerFilter.Selector
+
+	mutex sync.Mutex
+}
+
+// reload reads the configuration file again and applies it. Nothing is
+// changed if the file cannot be read.
+func (r *reloader) reload() (httpServer.ReloadResponse, error) {
+	config, err := readConfig(r.path)
+	if err != nil {
+		r.logger.Error("could not reload configuration", "path", r.path, "err", err)
+		return httpServer.ReloadResponse{}, err
+	}
+
+	result, err := r.apply(config)
+	if err != nil {
+		r.logger.Error("could not reload configuration", "path", r.path, "err", err)
+		return result, err
+	}
+
+	r.logger.Info("reloaded configuration",
+		"path", r.path,
+		"staticRecordsAdded", result.StaticRecordsAdded,
+		"staticRecordsRemoved", result.StaticRecordsRemoved,
+		"logLevel", result.LogLevel,
+		"ttl", result.Ttl,
+		"naming", result.Naming,
+		"conflictPolicy", result.ConflictPolicy,
+		"networkScope", result.NetworkScope,
+	)
+	return result, nil
+}
+
+func (r *reloader) apply(config Config) (result httpServer.ReloadResponse, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	level := r.defaultLogLevel
+	if config.LogLevel != "" {
+		if level, err = parseLogLevel(config.LogLevel); err != nil {
+			return
+		}
+	}
+
+	namingConfig := r.defaultNaming
+	if config.Naming.Strategy != "" {
+		namingConfig = config.Naming
+	}
+	strategy, err := naming.New(namingConfig, r.zone)
+	if err != nil {
+		return
+	}
+
+	filter, err := containerFilter.New(config.Filter)
+	if err != nil {
+		return
+	}
+
+	conflictPolicy := r.defaultConflictPolicy
+	if config.ConflictPolicy != "" {
+		conflictPolicy = config.ConflictPolicy
+	}
+	if err = dnsStorage.ValidateConflictPolicy(conflictPolicy); err != nil {
+		return
+	}
+
+	networkScope := r.defaultNetworkScope
+	if config.NetworkScope != "" {
+		networkScope = config.NetworkScope
+	}
+	if err = resolver.ValidateNetworkScope(networkScope); err != nil {
+		return
+	}
+
+	// unlike the settings above, invalid records are skipped and reported
+	// while the valid ones are applied
+	hosts := make(map[string]dnsStorage.Host, len(config.StaticRecords))
+	for _, record := range config.StaticRecords {
+		host, err := record.Host(dnsStorage.SourceStatic)
+		if err != nil {
+			r.logger.Error("invalid static record", "name", record.Name, "err", err)
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		hosts[host.Id] = host
+	}
+
+	// everything is valid, apply it
+	r.logLevel.Set(level)
+	r.resolver.SetTtl(config.Ttl)
+	r.names.Set(strategy)
+	r.storage.SetConflictPolicy(conflictPolicy)
+	r.resolver.SetNetworkScope(networkScope)
+	r.filters.Set(filter)
+
+	// records are identified by their content, changed records are replaced
+	existing := r.storage.GetHosts()
+	for id, host := range existing {
+		if _, ok := hosts[id]; host.Source == dnsStorage.SourceStatic && !ok {
+			r.logger.Info("remove static record", "name", host.Name, "id", id)
+			r.storage.RemoveHost(id)
+			result.StaticRecordsRemoved++
+		}
+	}
+	for id, host := range hosts {
+		if current, ok := existing[id]; ok && !current.IsRestored() {
+			continue
+		}
+		r.logger.Info("add static record", "name", host.Name, "id", id)
+		r.storage.AddHost(host)
+		result.StaticRecordsAdded++
+	}
+
+	result.LogLevel = level.String()
+	result.Ttl = config.Ttl
+	result.Naming = namingConfig.Strategy
+	result.ConflictPolicy = conflictPolicy
+	result.NetworkScope = networkScope
+	return
+}
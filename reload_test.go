@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/koestler/dnsdock/containerFilter"
+	"github.com/koestler/dnsdock/dnsStorage"
+	"github.com/koestler/dnsdock/naming"
+	"github.com/koestler/dnsdock/resolver"
+)
+
+func newTestReloader(t *testing.T) *reloader {
+	storage := dnsStorage.NewDnsStorage(slog.Default())
+	t.Cleanup(storage.Close)
+	dnsResolver, err := resolver.NewResolver(storage, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &reloader{
+		storage:               storage,
+		resolver:              dnsResolver,
+		logger:                slog.Default(),
+		logLevel:              new(slog.LevelVar),
+		defaultLogLevel:       slog.LevelInfo,
+		names:                 naming.NewSelector(nil),
+		defaultNaming:         naming.Config{Strategy: "compose"},
+		zone:                  "docker",
+		defaultConflictPolicy: dnsStorage.ConflictAllow,
+		defaultNetworkScope:   resolver.ScopePrefer,
+		filters:               containerFilter.NewSelector(nil),
+	}
+}
+
+func TestApplyIsAllOrNothing(t *testing.T) {
+	r := newTestReloader(t)
+	if _, err := r.apply(Config{}); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []Config{
+		{LogLevel: "debug", ConflictPolicy: dnsStorage.ConflictReject, NetworkScope: "bogus"},
+		{LogLevel: "debug", ConflictPolicy: "bogus", NetworkScope: resolver.ScopeRestrict},
+		{LogLevel: "debug", ConflictPolicy: dnsStorage.ConflictReject, Filter: containerFilter.Config{IncludeNames: []string{"("}}},
+	}
+	for _, config := range invalid {
+		if _, err := r.apply(config); err == nil {
+			t.Errorf("%+v: expected an error", config)
+		}
+		if policy := r.storage.ConflictPolicy(); policy != dnsStorage.ConflictAllow {
+			t.Errorf("%+v: expected the conflict policy to be kept, got %s", config, policy)
+		}
+		if level := r.logLevel.Level(); level != slog.LevelInfo {
+			t.Errorf("%+v: expected the log level to be kept, got %s", config, level)
+		}
+		if filter := r.filters.Get(); filter == nil {
+			t.Errorf("%+v: expected the filter to be kept", config)
+		}
+	}
+
+	if _, err := r.apply(Config{ConflictPolicy: dnsStorage.ConflictReject}); err != nil {
+		t.Fatal(err)
+	}
+	if policy := r.storage.ConflictPolicy(); policy != dnsStorage.ConflictReject {
+		t.Errorf("expected the conflict policy to be applied, got %s", policy)
+	}
+
+	// invalid records don't fail the reload
+	result, err := r.apply(Config{StaticRecords: []dnsStorage.Record{
+		{Name: "nas.docker", Type: "A", Value: "10.0.0.1"},
+		{Name: "printer.docker", Type: "A", Value: "not an address"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StaticRecordsAdded != 1 || len(result.Errors) != 1 {
+		t.Errorf("expected 1 record added and 1 error, got %+v", result)
+	}
+}
@@ -0,0 +1,50 @@
+package resolver
+
+import (
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/koestler/dnsdock/dnsStorage"
+	"github.com/miekg/dns"
+)
+
+func TestRefuseOutsideDomain(t *testing.T) {
+	storage := dnsStorage.NewDnsStorage(slog.Default())
+	defer storage.Close()
+
+	subscription := storage.Subscribe()
+	storage.AddHost(dnsStorage.Host{Id: "static_1", Name: "nas.lan", Address: net.ParseIP("10.0.0.1"), Source: dnsStorage.SourceStatic})
+	select {
+	case <-subscription.Changes:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for add")
+	}
+
+	r, err := NewResolver(storage, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Domain = "docker"
+
+	tests := []struct {
+		name  string
+		rcode int
+	}{
+		{"web.docker", dns.RcodeNameError},
+		{"WEB.Docker", dns.RcodeNameError},
+		// known names are answered in any zone
+		{"nas.lan", dns.RcodeSuccess},
+		{"example.com", dns.RcodeRefused},
+	}
+	for _, test := range tests {
+		response, err := r.Resolve(test.name, dns.TypeA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Rcode != test.rcode {
+			t.Errorf("%s: expected %s, got %s", test.name, dns.RcodeToString[test.rcode], dns.RcodeToString[response.Rcode])
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package resolver
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/koestler/dnsdock/dnsStorage"
+)
+
+// modes of resolving names depending on the network of the querying client
+const (
+	// ScopeOff answers with the addresses on all networks
+	ScopeOff = "off"
+	// ScopePrefer answers with the addresses on the network of the client if
+	// there are any, otherwise with all addresses
+	ScopePrefer = "prefer"
+	// ScopeRestrict only answers with addresses on the network of the client,
+	// unless the client is on no known network
+	ScopeRestrict = "restrict"
+)
+
+type clientNetwork struct {
+	name   string
+	subnet *net.IPNet
+}
+
+// SetNetworks sets the subnets of the docker networks by network name, used
+// to map clients to their network, and the gateways of the networks. Queries
+// from a gateway come from the host, e.g. forwarded by dnsmasq or resolved,
+// and are answered as for clients on no known network.
+func (r *DnsResolver) SetNetworks(networks map[string][]*net.IPNet, gateways []net.IP) {
+	var clientNetworks []clientNetwork
+	for name, subnets := range networks {
+		for _, subnet := range subnets {
+			clientNetworks = append(clientNetworks, clientNetwork{name: name, subnet: subnet})
+		}
+	}
+
+	r.networksMutex.Lock()
+	defer r.networksMutex.Unlock()
+
+	r.networks = clientNetworks
+	r.gateways = gateways
+}
+
+// ValidateNetworkScope checks that mode is one of ScopeOff, ScopePrefer or ScopeRestrict
+func ValidateNetworkScope(mode string) error {
+	switch mode {
+	case ScopeOff, ScopePrefer, ScopeRestrict:
+		return nil
+	}
+	return fmt.Errorf("unknown network scope %q", mode)
+}
+
+// SetNetworkScope sets one of ScopeOff, ScopePrefer or ScopeRestrict
+func (r *DnsResolver) SetNetworkScope(mode string) error {
+	if err := ValidateNetworkScope(mode); err != nil {
+		return err
+	}
+
+	r.networksMutex.Lock()
+	defer r.networksMutex.Unlock()
+
+	r.networkScope = mode
+	return nil
+}
+
+// clientNetwork returns the name of the network of the most specific subnet
+// containing the client address, or an empty string for unknown clients and
+// gateways
+func (r *DnsResolver) clientNetwork(client net.IP) (network string) {
+	if client == nil {
+		return
+	}
+
+	r.networksMutex.RLock()
+	defer r.networksMutex.RUnlock()
+
+	if r.networkScope == ScopeOff {
+		return
+	}
+
+	for _, gateway := range r.gateways {
+		if gateway.Equal(client) {
+			return
+		}
+	}
+
+	bestPrefix := -1
+	for _, n := range r.networks {
+		if !n.subnet.Contains(client) {
+			continue
+		}
+		if prefix, _ := n.subnet.Mask.Size(); prefix > bestPrefix {
+			bestPrefix = prefix
+			network = n.name
+		}
+	}
+	return
+}
+
+// scopeHosts reduces hosts to those reachable from network. Hosts without a
+// network, e.g. static records, are always kept.
+func (r *DnsResolver) scopeHosts(hosts []dnsStorage.Host, network string) []dnsStorage.Host {
+	if network == "" {
+		return hosts
+	}
+
+	r.networksMutex.RLock()
+	mode := r.networkScope
+	r.networksMutex.RUnlock()
+
+	scoped := make([]dnsStorage.Host, 0, len(hosts))
+	onNetwork := false
+	for _, host := range hosts {
+		if host.Network == network {
+			onNetwork = true
+		}
+		if host.Network == network || host.Network == "" {
+			scoped = append(scoped, host)
+		}
+	}
+
+	if mode == ScopePrefer && !onNetwork {
+		return hosts
+	}
+	return scoped
+}
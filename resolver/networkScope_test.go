@@ -0,0 +1,136 @@
+package resolver
+
+import (
+	"log/slog"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/koestler/dnsdock/dnsStorage"
+	"github.com/miekg/dns"
+)
+
+// clientResponseWriter records the answer to a client of the given address
+type clientResponseWriter struct {
+	dns.ResponseWriter
+	client   net.IP
+	response *dns.Msg
+}
+
+func (w *clientResponseWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: w.client, Port: 40000}
+}
+
+func (w *clientResponseWriter) WriteMsg(m *dns.Msg) error {
+	w.response = m
+	return nil
+}
+
+func TestNetworkScope(t *testing.T) {
+	storage := dnsStorage.NewDnsStorage(slog.Default())
+	defer storage.Close()
+
+	subscription := storage.Subscribe()
+	for _, host := range []dnsStorage.Host{
+		{Id: "web_front", Name: "web.shop.docker", Address: net.ParseIP("172.17.0.10"), Network: "front"},
+		{Id: "web_back", Name: "web.shop.docker", Address: net.ParseIP("172.18.0.10"), Network: "back"},
+		{Id: "db_back", Name: "db.shop.docker", Address: net.ParseIP("172.18.0.20"), Network: "back"},
+		{Id: "static_1", Name: "host.docker", Address: net.ParseIP("10.0.0.1"), Source: dnsStorage.SourceStatic},
+	} {
+		storage.AddHost(host)
+		select {
+		case <-subscription.Changes:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for add")
+		}
+	}
+
+	r, err := NewResolver(storage, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, front, _ := net.ParseCIDR("172.17.0.0/16")
+	_, back, _ := net.ParseCIDR("172.18.0.0/16")
+	_, backHosts, _ := net.ParseCIDR("172.18.0.0/24")
+	frontGateway := net.ParseIP("172.17.0.1")
+	r.SetNetworks(map[string][]*net.IPNet{"front": {front}, "back": {back}, "back_hosts": {backHosts}}, []net.IP{frontGateway})
+
+	frontClient := net.ParseIP("172.17.0.5")
+	unknownClient := net.ParseIP("192.168.1.5")
+
+	tests := []struct {
+		scope   string
+		client  net.IP
+		name    string
+		answers []string
+	}{
+		{ScopeOff, frontClient, "web.shop.docker.", []string{"172.17.0.10", "172.18.0.10"}},
+		{ScopePrefer, frontClient, "web.shop.docker.", []string{"172.17.0.10"}},
+		{ScopePrefer, frontClient, "db.shop.docker.", []string{"172.18.0.20"}},
+		{ScopeRestrict, frontClient, "web.shop.docker.", []string{"172.17.0.10"}},
+		{ScopeRestrict, frontClient, "db.shop.docker.", nil},
+		// static records have no network
+		{ScopeRestrict, frontClient, "host.docker.", []string{"10.0.0.1"}},
+		// clients on no known network get all addresses
+		{ScopeRestrict, unknownClient, "web.shop.docker.", []string{"172.17.0.10", "172.18.0.10"}},
+		{ScopeRestrict, unknownClient, "db.shop.docker.", []string{"172.18.0.20"}},
+		// the host queries through the gateway
+		{ScopeRestrict, frontGateway, "db.shop.docker.", []string{"172.18.0.20"}},
+		{ScopePrefer, frontGateway, "web.shop.docker.", []string{"172.17.0.10", "172.18.0.10"}},
+	}
+
+	for _, test := range tests {
+		if err := r.SetNetworkScope(test.scope); err != nil {
+			t.Fatal(err)
+		}
+
+		query := new(dns.Msg)
+		query.SetQuestion(test.name, dns.TypeA)
+		w := &clientResponseWriter{client: test.client}
+		r.ServeDNS(w, query)
+
+		var answers []string
+		for _, rr := range w.response.Answer {
+			answers = append(answers, rr.(*dns.A).A.String())
+		}
+		sort.Strings(answers)
+		if !reflect.DeepEqual(answers, test.answers) {
+			t.Errorf("%s %s from %s: expected %v, got %v", test.scope, test.name, test.client, test.answers, answers)
+		}
+	}
+
+	if err := r.SetNetworkScope("bogus"); err == nil {
+		t.Error("expected an error for an unknown scope")
+	}
+}
+
+func TestClientNetwork(t *testing.T) {
+	r := &DnsResolver{networkScope: ScopePrefer}
+	_, back, _ := net.ParseCIDR("172.18.0.0/16")
+	_, backHosts, _ := net.ParseCIDR("172.18.0.0/24")
+	r.SetNetworks(map[string][]*net.IPNet{"back": {back}, "back_hosts": {backHosts}}, []net.IP{net.ParseIP("172.18.0.1")})
+
+	tests := []struct {
+		client  net.IP
+		network string
+	}{
+		{net.ParseIP("172.18.0.5"), "back_hosts"},
+		{net.ParseIP("172.18.1.5"), "back"},
+		{net.ParseIP("192.168.1.5"), ""},
+		{net.ParseIP("172.18.0.1"), ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if network := r.clientNetwork(test.client); network != test.network {
+			t.Errorf("%s: expected network %q, got %q", test.client, test.network, network)
+		}
+	}
+
+	// the network is not looked up with the scope off
+	r.SetNetworkScope(ScopeOff)
+	if network := r.clientNetwork(net.ParseIP("172.18.0.5")); network != "" {
+		t.Errorf("expected no network with the scope off, got %q", network)
+	}
+}
@@ -0,0 +1,77 @@
+package resolver
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// QueryLogEntry describes a single answered query
+type QueryLogEntry struct {
+	Time    time.Time
+	Client  string
+	Name    string
+	Type    string
+	Rcode   string
+	Answers []string
+	// Latency is the time spent answering the query in seconds
+	Latency float64
+	// HostIds are the ids of the hosts of the storage matching the name
+	HostIds []string
+	// Network is the docker network of the client, if known
+	Network string `json:",omitempty"`
+}
+
+// QueryLog keeps the most recent queries in a ring buffer and optionally
+// writes every query as a json line to an output
+type QueryLog struct {
+	mutex   sync.Mutex
+	entries []QueryLogEntry
+	next    int
+	full    bool
+	output  io.Writer
+}
+
+// NewQueryLog creates a query log keeping size entries, output may be nil
+func NewQueryLog(size int, output io.Writer) *QueryLog {
+	return &QueryLog{
+		entries: make([]QueryLogEntry, size),
+		output:  output,
+	}
+}
+
+// Add keeps the entry and writes it to the output, only write errors are returned
+func (l *QueryLog) Add(entry QueryLogEntry) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.entries) > 0 {
+		l.entries[l.next] = entry
+		l.next = (l.next + 1) % len(l.entries)
+		if l.next == 0 {
+			l.full = true
+		}
+	}
+
+	if l.output == nil {
+		return nil
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = l.output.Write(append(b, '\n'))
+	return err
+}
+
+// Entries returns the kept entries, oldest first
+func (l *QueryLog) Entries() []QueryLogEntry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.full {
+		return append([]QueryLogEntry{}, l.entries[:l.next]...)
+	}
+	return append(append([]QueryLogEntry{}, l.entries[l.next:]...), l.entries[:l.next]...)
+}
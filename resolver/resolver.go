@@ -1,11 +1,18 @@
 package resolver
 
 import (
+	"context"
 	"fmt"
 	"github.com/koestler/dnsdock/dnsStorage"
+	"github.com/koestler/dnsdock/health"
+	"github.com/koestler/dnsdock/metrics"
 	"github.com/miekg/dns"
-	"log"
+	"log/slog"
 	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Resolver interface {
@@ -17,28 +24,98 @@ type Resolver interface {
 
 type DnsResolver struct {
 	Storage *dnsStorage.DnsStorage
-
-	Port       int
-	serverUdp  *dns.Server
-	serverTcp  *dns.Server
-	stoppedUdp chan struct{}
-	stoppedTcp chan struct{}
+	logger  *slog.Logger
+
+	Port         int
+	serverUdp    *dns.Server
+	serverTcp    *dns.Server
+	serversMutex sync.Mutex
+	stoppedUdp   chan struct{}
+	stoppedTcp   chan struct{}
+
+	// reverse zones this resolver is authoritative for, nil if unknown
+	reverseZones      []string
+	reverseZonesMutex sync.RWMutex
+
+	// time to live of all answers in seconds
+	ttl atomic.Uint32
+
+	// subnets of the docker networks for network scoped resolution
+	networks      []clientNetwork
+	gateways      []net.IP
+	networkScope  string
+	networksMutex sync.RWMutex
+
+	// QueryLog records answered queries if set
+	QueryLog *QueryLog
+
+	// Domain, if set, is the zone of the containers. Unknown names outside
+	// of it are refused instead of not found, so stub resolvers ask their
+	// next nameserver.
+	Domain string
+
+	// SelfCheckName, if set, is answered with a TXT record while Health is
+	// ready and with SERVFAIL otherwise, e.g. _dnsdock.docker
+	SelfCheckName string
+	Health        *health.Status
 }
 
-func NewResolver(storage *dnsStorage.DnsStorage) (*DnsResolver, error) {
+func NewResolver(storage *dnsStorage.DnsStorage, logger *slog.Logger) (*DnsResolver, error) {
 	return &DnsResolver{
-		Storage:    storage,
-		Port:       53,
-		stoppedUdp: make(chan struct{}),
-		stoppedTcp: make(chan struct{}),
+		Storage:      storage,
+		logger:       logger.With("component", "resolver"),
+		networkScope: ScopePrefer,
+		Port:         53,
+		stoppedUdp:   make(chan struct{}),
+		stoppedTcp:   make(chan struct{}),
 	}, nil
 }
 
+// SetTtl sets the time to live of the records in all following answers
+func (r *DnsResolver) SetTtl(ttl uint32) {
+	r.ttl.Store(ttl)
+}
+
+func (r *DnsResolver) Ttl() uint32 {
+	return r.ttl.Load()
+}
+
 func (r *DnsResolver) RemoveHost(id string) error {
 	r.Storage.RemoveHost(id)
 	return nil
 }
 
+// SetReverseZones sets the in-addr.arpa / ip6.arpa zones this resolver is
+// authoritative for. PTR queries outside of these zones are refused, unless
+// the address is known.
+func (r *DnsResolver) SetReverseZones(zones []string) {
+	r.reverseZonesMutex.Lock()
+	defer r.reverseZonesMutex.Unlock()
+
+	r.reverseZones = make([]string, len(zones))
+	for i, zone := range zones {
+		r.reverseZones[i] = strings.ToLower(dns.Fqdn(zone))
+	}
+}
+
+func (r *DnsResolver) isReverseZone(name string) bool {
+	r.reverseZonesMutex.RLock()
+	defer r.reverseZonesMutex.RUnlock()
+
+	// as long as the zones are unknown, answer all reverse queries
+	if r.reverseZones == nil {
+		return true
+	}
+
+	name = strings.ToLower(name)
+	for _, zone := range r.reverseZones {
+		if dns.IsSubDomain(zone, name) {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *DnsResolver) Listen() error {
 	addr := fmt.Sprintf(":%d", r.Port)
 
@@ -68,12 +145,14 @@ func (r *DnsResolver) Listen() error {
 	startupErrorUdp := make(chan error)
 	startupErrorTcp := make(chan error)
 
+	r.serversMutex.Lock()
 	r.serverUdp = &dns.Server{Handler: r, PacketConn: connUdp, NotifyStartedFunc: func() {
 		startupErrorUdp <- nil
 	}}
 	r.serverTcp = &dns.Server{Handler: r, Listener: connTcp, NotifyStartedFunc: func() {
 		startupErrorTcp <- nil
 	}}
+	r.serversMutex.Unlock()
 
 	go func() {
 		select {
@@ -115,66 +194,229 @@ func (r *DnsResolver) Wait() error {
 }
 
 func (r *DnsResolver) Close() {
-	if r.serverUdp != nil {
-		r.serverUdp.Shutdown()
-	}
-	if r.serverTcp != nil {
-		r.serverTcp.Shutdown()
+	r.Shutdown(context.Background())
+}
+
+// Shutdown stops the listeners and waits for in-flight queries to be answered
+// until ctx is done
+func (r *DnsResolver) Shutdown(ctx context.Context) error {
+	r.serversMutex.Lock()
+	servers := []*dns.Server{r.serverUdp, r.serverTcp}
+	r.serversMutex.Unlock()
+
+	var err error
+	for _, server := range servers {
+		if server == nil {
+			continue
+		}
+		if e := server.ShutdownContext(ctx); e != nil && err == nil {
+			err = e
+		}
 	}
+	return err
 }
 
 func (r *DnsResolver) ServeDNS(w dns.ResponseWriter, query *dns.Msg) {
-	response, err := r.responseForQuery(query)
+	if len(query.Question) == 0 {
+		resp := new(dns.Msg)
+		resp.SetRcode(query, dns.RcodeFormatError)
+		w.WriteMsg(resp)
+		return
+	}
+
+	start := time.Now()
+	qtype := dns.TypeToString[query.Question[0].Qtype]
+
+	var client net.IP
+	switch addr := w.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		client = addr.IP
+	case *net.TCPAddr:
+		client = addr.IP
+	}
+	network := r.clientNetwork(client)
+
+	response, hostIds, err := r.responseForQuery(query, network)
 	if err != nil {
-		log.Printf("response error: %T %s", err, err)
+		r.logger.Error("could not answer query", "name", query.Question[0].Name, "qtype", qtype, "err", err)
 		return
 	}
 	if response == nil {
 		return
 	}
 
+	latency := time.Since(start)
+	rcode := dns.RcodeToString[response.Rcode]
+	metrics.DnsQueries.WithLabelValues(qtype, rcode).Inc()
+	metrics.DnsQueryDuration.WithLabelValues(qtype).Observe(latency.Seconds())
+
+	if r.QueryLog != nil {
+		entry := QueryLogEntry{
+			Time:    start,
+			Name:    query.Question[0].Name,
+			Type:    qtype,
+			Rcode:   rcode,
+			Answers: []string{},
+			Latency: latency.Seconds(),
+			HostIds: hostIds,
+			Network: network,
+		}
+		if client != nil {
+			entry.Client = client.String()
+		}
+		for _, rr := range response.Answer {
+			entry.Answers = append(entry.Answers, rr.String())
+		}
+		if err := r.QueryLog.Add(entry); err != nil {
+			r.logger.Warn("could not write query log", "err", err)
+		}
+	}
+
 	err = w.WriteMsg(response)
 	if err != nil {
-		log.Println("write error:", err)
+		r.logger.Warn("could not write response", "err", err)
 	}
 }
 
-func (r *DnsResolver) responseForQuery(query *dns.Msg) (*dns.Msg, error) {
+// Resolve answers a query for name and qtype the same way as a query received
+// by the dns server, without going through the network
+func (r *DnsResolver) Resolve(name string, qtype uint16) (*dns.Msg, error) {
+	query := new(dns.Msg)
+	query.SetQuestion(dns.Fqdn(name), qtype)
+	response, _, err := r.responseForQuery(query, "")
+	return response, err
+}
+
+// responseForQuery answers the query of a client on network, which is empty
+// if unknown, and returns the ids of the matching hosts
+func (r *DnsResolver) responseForQuery(query *dns.Msg, network string) (*dns.Msg, []string, error) {
 	// answer to first question
 	name := query.Question[0].Name
+	qtype := query.Question[0].Qtype
+
+	if r.SelfCheckName != "" && strings.EqualFold(name, dns.Fqdn(r.SelfCheckName)) {
+		return r.dnsSelfCheck(query, name, qtype), nil, nil
+	}
 
-	if query.Question[0].Qtype == dns.TypeA {
-		if addrs := r.Storage.FindHostAddresses(name); len(addrs) > 0 {
-			return dnsAddressRecord(query, name, addrs), nil
+	switch qtype {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeTXT:
+		if hosts := r.scopeHosts(r.Storage.FindHosts(name), network); len(hosts) > 0 {
+			hostIds := make([]string, len(hosts))
+			for i, host := range hosts {
+				hostIds[i] = host.Id
+			}
+			return r.dnsHostRecords(query, name, qtype, hosts, network), hostIds, nil
 		}
-	} else if query.Question[0].Qtype == dns.TypePTR {
+	case dns.TypePTR:
+		// known addresses outside of the docker networks are answered too,
+		// e.g. HOST_IP for --net=host containers
 		if hosts := r.Storage.FindReverseHost(name); len(hosts) > 0 {
-			return dnsPtrRecord(query, name, hosts), nil
+			resp := dnsPtrRecord(query, name, hosts, r.Ttl())
+			resp.Authoritative = true
+			return resp, nil, nil
+		}
+		if !r.isReverseZone(name) {
+			return dnsRefused(query), nil, nil
 		}
+		resp := dnsNotFound(query)
+		resp.Authoritative = true
+		return resp, nil, nil
 	}
 
-	return dnsNotFound(query), nil
+	if r.Domain != "" && !dns.IsSubDomain(dns.Fqdn(strings.TrimPrefix(r.Domain, ".")), name) && len(r.Storage.FindHosts(name)) == 0 {
+		return dnsRefused(query), nil, nil
+	}
+	return dnsNotFound(query), nil, nil
 }
 
-func dnsAddressRecord(query *dns.Msg, name string, addrs []net.IP) *dns.Msg {
+// dnsHostRecords answers a query for a name known to the storage. Names without
+// records of the requested type result in an empty answer.
+func (r *DnsResolver) dnsHostRecords(query *dns.Msg, name string, qtype uint16, hosts []dnsStorage.Host, network string) *dns.Msg {
 	resp := new(dns.Msg)
 	resp.SetReply(query)
+	ttl := r.Ttl()
+
+	for _, host := range hosts {
+		if host.Target != "" {
+			target := dns.Fqdn(host.Target)
+			rr := new(dns.CNAME)
+			rr.Hdr = dns.RR_Header{Name: name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: ttl}
+			rr.Target = target
+			resp.Answer = append(resp.Answer, rr)
+
+			// follow the alias if the target is a local name as well
+			if qtype == dns.TypeA || qtype == dns.TypeAAAA {
+				var addrs []net.IP
+				for _, targetHost := range r.scopeHosts(r.Storage.FindHosts(target), network) {
+					if targetHost.Address != nil {
+						addrs = append(addrs, targetHost.Address)
+					}
+				}
+				resp.Answer = append(resp.Answer, addressRecords(target, qtype, addrs, ttl)...)
+			}
+			continue
+		}
+
+		switch qtype {
+		case dns.TypeA, dns.TypeAAAA:
+			if host.Address != nil {
+				resp.Answer = append(resp.Answer, addressRecords(name, qtype, []net.IP{host.Address}, ttl)...)
+			}
+		case dns.TypeTXT:
+			if len(host.Text) > 0 {
+				rr := new(dns.TXT)
+				rr.Hdr = dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: ttl}
+				rr.Txt = host.Text
+				resp.Answer = append(resp.Answer, rr)
+			}
+		}
+	}
+	return resp
+}
+
+// addressRecords returns A or AAAA records for those addresses matching qtype
+func addressRecords(name string, qtype uint16, addrs []net.IP, ttl uint32) (rrs []dns.RR) {
 	for _, addr := range addrs {
-		rr := new(dns.A)
-		rr.Hdr = dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0}
-		rr.A = addr
+		if ipv4 := addr.To4(); ipv4 != nil && qtype == dns.TypeA {
+			rr := new(dns.A)
+			rr.Hdr = dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl}
+			rr.A = ipv4
+			rrs = append(rrs, rr)
+		} else if ipv4 == nil && qtype == dns.TypeAAAA {
+			rr := new(dns.AAAA)
+			rr.Hdr = dns.RR_Header{Name: name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: ttl}
+			rr.AAAA = addr
+			rrs = append(rrs, rr)
+		}
+	}
+	return
+}
 
+// dnsSelfCheck answers the self-check name according to the health status
+func (r *DnsResolver) dnsSelfCheck(query *dns.Msg, name string, qtype uint16) *dns.Msg {
+	resp := new(dns.Msg)
+	if r.Health != nil && !r.Health.Ready() {
+		resp.SetRcode(query, dns.RcodeServerFailure)
+		return resp
+	}
+
+	resp.SetReply(query)
+	resp.Authoritative = true
+	if qtype == dns.TypeTXT {
+		rr := new(dns.TXT)
+		rr.Hdr = dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 0}
+		rr.Txt = []string{"ready"}
 		resp.Answer = append(resp.Answer, rr)
 	}
 	return resp
 }
 
-func dnsPtrRecord(query *dns.Msg, name string, hosts []string) *dns.Msg {
+func dnsPtrRecord(query *dns.Msg, name string, hosts []string, ttl uint32) *dns.Msg {
 	resp := new(dns.Msg)
 	resp.SetReply(query)
 	for _, host := range hosts {
 		rr := new(dns.PTR)
-		rr.Hdr = dns.RR_Header{Name: name, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: 0}
+		rr.Hdr = dns.RR_Header{Name: name, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: ttl}
 		rr.Ptr = host
 
 		resp.Answer = append(resp.Answer, rr)
@@ -188,3 +430,10 @@ func dnsNotFound(query *dns.Msg) *dns.Msg {
 	resp.SetRcode(query, dns.RcodeNameError)
 	return resp
 }
+
+func dnsRefused(query *dns.Msg) *dns.Msg {
+	resp := new(dns.Msg)
+	resp.SetReply(query)
+	resp.SetRcode(query, dns.RcodeRefused)
+	return resp
+}
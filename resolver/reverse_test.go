@@ -0,0 +1,55 @@
+package resolver
+
+import (
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/koestler/dnsdock/dnsStorage"
+	"github.com/miekg/dns"
+)
+
+func TestReverseZones(t *testing.T) {
+	storage := dnsStorage.NewDnsStorage(slog.Default())
+	defer storage.Close()
+
+	subscription := storage.Subscribe()
+	for _, host := range []dnsStorage.Host{
+		{Id: "web_bridge", Name: "web.docker", Address: net.ParseIP("172.17.0.2"), Network: "bridge", Source: dnsStorage.SourceContainer},
+		{Id: "agent_host", Name: "agent.docker", Address: net.ParseIP("192.168.42.42"), Source: dnsStorage.SourceContainer},
+	} {
+		storage.AddHost(host)
+		select {
+		case <-subscription.Changes:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for add")
+		}
+	}
+
+	r, err := NewResolver(storage, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetReverseZones([]string{"17.172.in-addr.arpa"})
+
+	tests := []struct {
+		name  string
+		rcode int
+	}{
+		{"2.0.17.172.in-addr.arpa", dns.RcodeSuccess},
+		{"3.0.17.172.in-addr.arpa", dns.RcodeNameError},
+		// known addresses outside of the zones, e.g. HOST_IP
+		{"42.42.168.192.in-addr.arpa", dns.RcodeSuccess},
+		{"43.42.168.192.in-addr.arpa", dns.RcodeRefused},
+	}
+	for _, test := range tests {
+		response, err := r.Resolve(test.name, dns.TypePTR)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Rcode != test.rcode {
+			t.Errorf("%s: expected %s, got %s", test.name, dns.RcodeToString[test.rcode], dns.RcodeToString[response.Rcode])
+		}
+	}
+}
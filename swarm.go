@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	dockerapi "github.com/fsouza/go-dockerclient"
+	"github.com/koestler/dnsdock/containerFilter"
+	"github.com/koestler/dnsdock/dnsStorage"
+)
+
+// swarmDiscovery registers the virtual ips of swarm services as
+// <service>.<zone> and the addresses of their running tasks as
+// tasks.<service>.<zone>, like the embedded dns of swarm networks does.
+// It needs to run on a manager node.
+type swarmDiscovery struct {
+	docker   *dockerapi.Client
+	storage  *dnsStorage.DnsStorage
+	filters  *containerFilter.Selector
+	zone     string
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+// swarmHosts returns the hosts of the services and their tasks by id.
+// Services without running tasks get no records, the ingress network is
+// left out as it only serves published ports. Services and networks are
+// filtered like containers.
+func swarmHosts(services []swarm.Service, tasks []swarm.Task, zone string, filter *containerFilter.Filter) map[string]dnsStorage.Host {
+	zone = strings.TrimPrefix(zone, ".")
+	hosts := make(map[string]dnsStorage.Host)
+
+	byId := make(map[string]swarm.Service, len(services))
+	for _, service := range services {
+		if filter.Service(service) {
+			byId[service.ID] = service
+		}
+	}
+
+	// networks the running tasks of each service are attached to, by id
+	networks := make(map[string]map[string]swarm.Network)
+	for _, task := range tasks {
+		service, ok := byId[task.ServiceID]
+		if !ok || task.Status.State != swarm.TaskStateRunning {
+			continue
+		}
+		name := strings.ToLower(service.Spec.Name)
+
+		var aliases []string
+		if service.Endpoint.Spec.Mode == swarm.ResolutionModeDNSRR {
+			// without a virtual ip the service name resolves to its tasks
+			aliases = []string{name + "." + zone}
+		}
+
+		for _, attachment := range task.NetworksAttachments {
+			network := attachment.Network
+			if network.Spec.Ingress || !filter.Network(network.Spec.Name) {
+				continue
+			}
+			if networks[service.ID] == nil {
+				networks[service.ID] = make(map[string]swarm.Network)
+			}
+			networks[service.ID][network.ID] = network
+
+			for _, address := range attachment.Addresses {
+				addr, _, err := net.ParseCIDR(address)
+				if err != nil {
+					continue
+				}
+				id := task.ID + "_" + network.Spec.Name + "_" + addr.String()
+				hosts[id] = dnsStorage.Host{
+					Id:      id,
+					Address: addr,
+					Name:    "tasks." + name + "." + zone,
+					Aliases: aliases,
+					Network: network.Spec.Name,
+					Source:  dnsStorage.SourceSwarm,
+				}
+			}
+		}
+	}
+
+	for _, service := range byId {
+		for _, vip := range service.Endpoint.VirtualIPs {
+			network, ok := networks[service.ID][vip.NetworkID]
+			if !ok {
+				continue
+			}
+			addr, _, err := net.ParseCIDR(vip.Addr)
+			if err != nil {
+				continue
+			}
+			id := service.ID + "_" + network.Spec.Name + "_" + addr.String()
+			hosts[id] = dnsStorage.Host{
+				Id:      id,
+				Address: addr,
+				Name:    strings.ToLower(service.Spec.Name) + "." + zone,
+				Network: network.Spec.Name,
+				Source:  dnsStorage.SourceSwarm,
+			}
+		}
+	}
+
+	return hosts
+}
+
+// sync lists the services and tasks and updates their hosts in the storage
+func (s *swarmDiscovery) sync() error {
+	services, err := s.docker.ListServices(dockerapi.ListServicesOptions{})
+	if err != nil {
+		return err
+	}
+	tasks, err := s.docker.ListTasks(dockerapi.ListTasksOptions{
+		Filters: map[string][]string{"desired-state": {"running"}},
+	})
+	if err != nil {
+		return err
+	}
+
+	hosts := swarmHosts(services, tasks, s.zone, s.filters.Get())
+
+	// tasks keep their addresses, a changed service gets new tasks
+	existing := s.storage.GetHosts()
+	for id, host := range existing {
+		if _, ok := hosts[id]; host.Source == dnsStorage.SourceSwarm && !ok {
+			s.logger.Info("remove swarm record", "name", host.Name, "id", id)
+			s.storage.RemoveHost(id)
+		}
+	}
+	for id, host := range hosts {
+		if current, ok := existing[id]; ok && !current.IsRestored() {
+			continue
+		}
+		s.logger.Info("add swarm record", "name", host.Name, "ip", host.Address, "id", id)
+		s.storage.AddHost(host)
+	}
+
+	return nil
+}
+
+// watch syncs whenever a service changes and every interval, as tasks are
+// rescheduled without events on other nodes, until ctx is done
+func (s *swarmDiscovery) watch(ctx context.Context) error {
+	events := make(chan *dockerapi.APIEvents, dockerEventBuffer)
+	if err := s.docker.AddEventListener(events); err != nil {
+		return err
+	}
+
+	// e.g. not a manager node yet, retried every interval
+	if err := s.sync(); err != nil {
+		s.logger.Error("could not sync swarm services", "err", err)
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg := <-events:
+			if msg == nil {
+				return errors.New("docker service event loop closed")
+			}
+			if msg.Type != "service" {
+				continue
+			}
+			s.logger.Debug("service changed", "action", msg.Action, "service", msg.Actor.ID)
+
+			// a single sync covers all changes queued meanwhile
+		drain:
+			for {
+				select {
+				case queued := <-events:
+					if queued == nil {
+						return errors.New("docker service event loop closed")
+					}
+				default:
+					break drain
+				}
+			}
+		case <-ticker.C:
+		case <-ctx.Done():
+			s.docker.RemoveEventListener(events)
+			return nil
+		}
+
+		if err := s.sync(); err != nil {
+			s.logger.Error("could not sync swarm services", "err", err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/koestler/dnsdock/containerFilter"
+)
+
+func TestSwarmHosts(t *testing.T) {
+	backend := swarm.Network{ID: "n1", Spec: swarm.NetworkSpec{Annotations: swarm.Annotations{Name: "shop_backend"}}}
+	ingress := swarm.Network{ID: "n2", Spec: swarm.NetworkSpec{Annotations: swarm.Annotations{Name: "ingress"}, Ingress: true}}
+
+	web := swarm.Service{ID: "s1"}
+	web.Spec.Name = "shop_web"
+	web.Endpoint.VirtualIPs = []swarm.EndpointVirtualIP{
+		{NetworkID: "n1", Addr: "10.0.1.2/24"},
+		{NetworkID: "n2", Addr: "10.0.0.2/24"},
+	}
+	db := swarm.Service{ID: "s2"}
+	db.Spec.Name = "shop_db"
+	db.Endpoint.Spec.Mode = swarm.ResolutionModeDNSRR
+	idle := swarm.Service{ID: "s3"}
+	idle.Spec.Name = "shop_idle"
+	idle.Endpoint.VirtualIPs = []swarm.EndpointVirtualIP{{NetworkID: "n1", Addr: "10.0.1.9/24"}}
+
+	task := func(id, service, address string, state swarm.TaskState) swarm.Task {
+		return swarm.Task{
+			ID:        id,
+			ServiceID: service,
+			Status:    swarm.TaskStatus{State: state},
+			NetworksAttachments: []swarm.NetworkAttachment{
+				{Network: backend, Addresses: []string{address}},
+				{Network: ingress, Addresses: []string{"10.0.0.99/24"}},
+			},
+		}
+	}
+	tasks := []swarm.Task{
+		task("t1", "s1", "10.0.1.3/24", swarm.TaskStateRunning),
+		task("t2", "s1", "10.0.1.4/24", swarm.TaskStateRunning),
+		task("t3", "s1", "10.0.1.5/24", swarm.TaskStateStarting),
+		task("t4", "s2", "10.0.1.6/24", swarm.TaskStateRunning),
+	}
+
+	filter, _ := containerFilter.New(containerFilter.Config{})
+	hosts := swarmHosts([]swarm.Service{web, db, idle}, tasks, ".docker", filter)
+
+	var records []string
+	for _, host := range hosts {
+		if host.Source != "swarm" || host.Network != "shop_backend" {
+			t.Errorf("unexpected host %+v", host)
+		}
+		record := host.Name + " " + host.Address.String()
+		for _, alias := range host.Aliases {
+			record += " " + alias
+		}
+		records = append(records, record)
+	}
+	sort.Strings(records)
+
+	expected := []string{
+		"shop_web.docker 10.0.1.2",
+		"tasks.shop_db.docker 10.0.1.6 shop_db.docker",
+		"tasks.shop_web.docker 10.0.1.3",
+		"tasks.shop_web.docker 10.0.1.4",
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, records)
+	}
+	for i := range expected {
+		if records[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], records[i])
+		}
+	}
+
+	// filtered networks are left out
+	filter, _ = containerFilter.New(containerFilter.Config{ExcludeNetworks: []string{"shop_*"}})
+	if hosts := swarmHosts([]swarm.Service{web, db}, tasks, "docker", filter); len(hosts) != 0 {
+		t.Errorf("expected no hosts, got %v", hosts)
+	}
+
+	// filtered services are left out with their tasks
+	filter, _ = containerFilter.New(containerFilter.Config{ExcludeNames: []string{"^shop_web$"}})
+	hosts = swarmHosts([]swarm.Service{web, db}, tasks, "docker", filter)
+	if _, ok := hosts["t4_shop_backend_10.0.1.6"]; len(hosts) != 1 || !ok {
+		t.Errorf("expected the task of shop_db only, got %v", hosts)
+	}
+}